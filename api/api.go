@@ -4,20 +4,34 @@ import (
 	"github.com/gin-gonic/gin"
 	v1 "github.com/ibrat-muslim/blog_app_api_gateway/api/v1"
 	"github.com/ibrat-muslim/blog_app_api_gateway/config"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
 	swaggerFiles "github.com/swaggo/files"     // swagger embed files
 	ginSwagger "github.com/swaggo/gin-swagger" // gin-swagger middleware
 
 	grpcPkg "github.com/ibrat-muslim/blog_app_api_gateway/pkg/grpc_client"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/metrics"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/ratelimit"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/rbac"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/requestid"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/token"
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage"
 
 	_ "github.com/ibrat-muslim/blog_app_api_gateway/api/docs" // for swagger
 )
 
 type RouterOptions struct {
-	Cfg        *config.Config
-	GrpcClient grpcPkg.GrpcClientI
-	Logger     *logrus.Logger
+	Cfg          *config.Config
+	GrpcClient   grpcPkg.GrpcClientI
+	Storage      storage.StorageI
+	Logger       *logrus.Logger
+	RateLimits   ratelimit.Policies
+	IPRateLimits ratelimit.Policies
+	Verifier     *token.Verifier
+	Policy       *rbac.Engine
+	TokenMaker   *token.Maker
 }
 
 // @title           Swagger for blog api
@@ -29,38 +43,80 @@ type RouterOptions struct {
 // @in header
 // @name Authorization
 // @Security ApiKeyAuth
-func New(opt *RouterOptions) *gin.Engine {
-	router := gin.Default()
-
+func New(opt *RouterOptions) (*gin.Engine, error) {
 	handlerV1 := v1.New(&v1.HandlerV1Options{
-		Cfg:        opt.Cfg,
-		GrpcClient: opt.GrpcClient,
-		Logger:     opt.Logger,
+		Cfg:          opt.Cfg,
+		GrpcClient:   opt.GrpcClient,
+		Storage:      opt.Storage,
+		Logger:       opt.Logger,
+		RateLimits:   opt.RateLimits,
+		IPRateLimits: opt.IPRateLimits,
+		Verifier:     opt.Verifier,
+		Policy:       opt.Policy,
+		TokenMaker:   opt.TokenMaker,
 	})
 
+	router := gin.New()
+
+	// Without trusted proxies ClientIP ignores X-Forwarded-For, which would
+	// otherwise let clients pick their own rate limit and lockout keys.
+	if err := router.SetTrustedProxies(opt.Cfg.TrustedProxies); err != nil {
+		return nil, err
+	}
+
+	router.Use(requestid.Middleware())
+	router.Use(handlerV1.AccessLogMiddleware())
+	router.Use(handlerV1.RecoveryMiddleware())
+	router.Use(handlerV1.HSTSMiddleware())
+	router.Use(otelgin.Middleware(opt.Cfg.ServiceName))
+	router.Use(metrics.GinMiddleware())
+
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	router.GET("/healthz", handlerV1.Healthz)
+	router.GET("/readyz", handlerV1.Readyz)
+
 	apiV1 := router.Group("/v1")
+	apiV1.Use(handlerV1.RateLimitMiddleware())
+	userRateLimit := handlerV1.UserRateLimitMiddleware()
 
 	apiV1.POST("/auth/register", handlerV1.Register)
 	apiV1.POST("/auth/verify", handlerV1.Verify)
 	apiV1.POST("/auth/login", handlerV1.Login)
 	apiV1.POST("/auth/forgot-password", handlerV1.ForgotPassword)
 	apiV1.POST("/auth/verify-forgot-password", handlerV1.VerifyForgotPassword)
-	apiV1.POST("/auth/update-password", handlerV1.AuthMiddleware("users", "update-password"), handlerV1.UpdatePassword)
+	apiV1.POST("/auth/refresh", handlerV1.RefreshToken)
+	apiV1.POST("/auth/logout", handlerV1.Logout)
+	apiV1.POST("/auth/update-password", handlerV1.AuthMiddleware("users", "update-password"), userRateLimit, handlerV1.UpdatePassword)
 
 	apiV1.GET("/users/:id", handlerV1.GetUser)
-	apiV1.GET("/users/me", handlerV1.AuthMiddleware("users", "get-user-profile"), handlerV1.GetUserProfile)
+	apiV1.GET("/users/me", handlerV1.AuthMiddleware("users", "get-user-profile"), userRateLimit, handlerV1.GetUserProfile)
 	apiV1.GET("/users", handlerV1.GetUsers)
 	apiV1.GET("/users/email/:email", handlerV1.GetUserByEmail)
-	apiV1.POST("/users", handlerV1.AuthMiddleware("users", "create"), handlerV1.CreateUser)
-	apiV1.PUT("/users/:id", handlerV1.AuthMiddleware("users", "update"), handlerV1.UpdateUser)
-	apiV1.DELETE("users/:id", handlerV1.AuthMiddleware("users", "delete"), handlerV1.DeleteUser)
+	apiV1.POST("/users", handlerV1.AuthMiddleware("users", "create"), userRateLimit, handlerV1.CreateUser)
+	apiV1.PUT("/users/:id", handlerV1.AuthMiddleware("users", "update"), userRateLimit, handlerV1.OwnershipMiddleware("users"), handlerV1.UpdateUser)
+	apiV1.DELETE("users/:id", handlerV1.AuthMiddleware("users", "delete"), userRateLimit, handlerV1.OwnershipMiddleware("users"), handlerV1.DeleteUser)
+
+	apiV1.POST("/posts", handlerV1.AuthMiddleware("posts", "create"), userRateLimit, handlerV1.CreatePost)
+	apiV1.GET("/posts/:id", handlerV1.GetPost)
+	apiV1.GET("/posts", handlerV1.GetPosts)
+	apiV1.PUT("/posts/:id", handlerV1.AuthMiddleware("posts", "update"), userRateLimit, handlerV1.OwnershipMiddleware("posts"), handlerV1.UpdatePost)
+	apiV1.DELETE("/posts/:id", handlerV1.AuthMiddleware("posts", "delete"), userRateLimit, handlerV1.OwnershipMiddleware("posts"), handlerV1.DeletePost)
+
+	apiV1.GET("/posts/:id/likes", handlerV1.GetPostLikeInfo)
+	apiV1.POST("/posts/:id/like", handlerV1.AuthMiddleware("likes", "create"), userRateLimit, handlerV1.CreateOrUpdateLike)
+	apiV1.DELETE("/posts/:id/like", handlerV1.AuthMiddleware("likes", "delete"), userRateLimit, handlerV1.DeleteLike)
+
+	apiV1.GET("/categories/:id", handlerV1.GetCategory)
+	apiV1.GET("/categories", handlerV1.GetCategories)
+	apiV1.POST("/categories", handlerV1.AuthMiddleware("categories", "create"), userRateLimit, handlerV1.CreateCategory)
+	apiV1.PUT("/categories/:id", handlerV1.AuthMiddleware("categories", "update"), userRateLimit, handlerV1.UpdateCategory)
+	apiV1.DELETE("/categories/:id", handlerV1.AuthMiddleware("categories", "delete"), userRateLimit, handlerV1.DeleteCategory)
 
-	apiV1.POST("/posts", handlerV1.AuthMiddleware("posts", "create"), handlerV1.CreatePost)
-	apiV1.PUT("/posts/:id", handlerV1.AuthMiddleware("posts", "update"), handlerV1.UpdatePost)
+	apiV1.POST("/notifications/email", handlerV1.AuthMiddleware("notifications", "send-email"), userRateLimit, handlerV1.SendEmail)
 
-	apiV1.POST("/categories", handlerV1.AuthMiddleware("categories", "create"), handlerV1.CreateCategory)
+	apiV1.GET("/admin/policies/:role", handlerV1.AuthMiddleware("policies", "get"), userRateLimit, handlerV1.GetRolePolicy)
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	return router
+	return router, nil
 }
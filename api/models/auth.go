@@ -8,14 +8,15 @@ type RegisterRequest struct {
 }
 
 type AuthResponse struct {
-	ID          int64  `json:"id"`
-	FirstName   string `json:"first_name"`
-	LastName    string `json:"last_name"`
-	Email       string `json:"email"`
-	Username    string `json:"username"`
-	Type        string `json:"type"`
-	CreatedAt   string `json:"created_at"`
-	AccessToken string `json:"access_token"`
+	ID           int64  `json:"id"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Email        string `json:"email"`
+	Username     string `json:"username"`
+	Type         string `json:"type"`
+	CreatedAt    string `json:"created_at"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
 
 type LoginRequest struct {
@@ -35,3 +36,12 @@ type ForgotPasswordRequest struct {
 type UpdatePasswordRequest struct {
 	Password string `json:"password" binding:"required,min=6,max=16"`
 }
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
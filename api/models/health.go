@@ -0,0 +1,11 @@
+package models
+
+type HealthResponse struct {
+	Status   string                   `json:"status"`
+	Services map[string]ServiceHealth `json:"services,omitempty"`
+}
+
+type ServiceHealth struct {
+	Status  string `json:"status"`
+	Breaker string `json:"breaker"`
+}
@@ -0,0 +1,13 @@
+package models
+
+type Like struct {
+	ID        int64  `json:"id"`
+	PostID    int64  `json:"post_id"`
+	UserID    int64  `json:"user_id"`
+	Status    string `json:"status"`
+	CreatedAt string `json:"created_at"`
+}
+
+type CreateOrUpdateLikeRequest struct {
+	Status string `json:"status" binding:"required,oneof=like dislike"`
+}
@@ -0,0 +1,8 @@
+package models
+
+type SendEmailRequest struct {
+	To      string            `json:"to" binding:"required,email"`
+	Type    string            `json:"type" binding:"required"`
+	Subject string            `json:"subject" binding:"required,max=200"`
+	Body    map[string]string `json:"body"`
+}
@@ -0,0 +1,11 @@
+package models
+
+type PolicyRule struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+}
+
+type RolePolicy struct {
+	Role  string        `json:"role"`
+	Rules []*PolicyRule `json:"rules"`
+}
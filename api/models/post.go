@@ -31,7 +31,9 @@ type GetPostsParams struct {
 	Search     string `json:"search"`
 	UserID     int64  `json:"user_id"`
 	CategoryID int64  `json:"category_id"`
-	SortByDate string `json:"sort_by_date" enums:"asc,desc" default:"desc"`
+	// SortByDate orders the posts by creation date. Without it the posts
+	// come in the order of the post service.
+	SortByDate string `json:"sort_by_date" enums:"asc,desc"`
 }
 
 type GetPostsResponse struct {
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// NewRedirectHandler redirects every plain HTTP request to the same URL on
+// the HTTPS listener at httpsAddr.
+func NewRedirectHandler(httpsAddr string) http.Handler {
+	_, httpsPort, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			// No port, but an IPv6 host still comes in brackets.
+			host = strings.Trim(r.Host, "[]")
+		}
+
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsAddr string
+		host      string
+		want      string
+	}{
+		{"host", ":8443", "example.com", "https://example.com:8443/v1/posts?page=2"},
+		{"host and port", ":8443", "example.com:8080", "https://example.com:8443/v1/posts?page=2"},
+		{"default port", ":443", "example.com:8080", "https://example.com/v1/posts?page=2"},
+		{"ipv4", ":8443", "127.0.0.1:8080", "https://127.0.0.1:8443/v1/posts?page=2"},
+		{"ipv6 and port", ":8443", "[::1]:8080", "https://[::1]:8443/v1/posts?page=2"},
+		{"ipv6", ":8443", "[::1]", "https://[::1]:8443/v1/posts?page=2"},
+		{"ipv6 on default port", ":443", "[::1]", "https://[::1]/v1/posts?page=2"},
+		{"ipv6 and port on default port", ":443", "[::1]:8080", "https://[::1]/v1/posts?page=2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/posts?page=2", nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+
+			NewRedirectHandler(tt.httpsAddr).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusPermanentRedirect {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusPermanentRedirect)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Fatalf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
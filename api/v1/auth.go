@@ -1,14 +1,17 @@
 package v1
 
 import (
-	"context"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ibrat-muslim/blog_app_api_gateway/api/models"
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
 	pbu "github.com/ibrat-muslim/blog_app_api_gateway/genproto/user_service"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/token"
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage/repo"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 // @Router /auth/register [post]
@@ -20,38 +23,46 @@ import (
 // @Param data body models.RegisterRequest true "Data"
 // @Success 200 {object} models.OKResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 func (h *handlerV1) Register(ctx *gin.Context) {
 
 	var req models.RegisterRequest
 
 	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
 	if !validatePassword(req.Password) {
-		ctx.JSON(http.StatusBadRequest, errorResponse(ErrWeakPassword))
+		h.errorResponse(ctx, http.StatusBadRequest, ErrWeakPassword)
 		return
 	}
 
-	user, _ := h.grpcClient.UserService().GetByEmail(context.Background(), &pbu.EmailRequest{
+	c, cancel := h.serviceContext(ctx, config.UserService)
+	defer cancel()
+
+	user, _ := h.grpcClient.UserService().GetByEmail(c, &pbu.EmailRequest{
 		Email: req.Email,
 	})
 	if user != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(ErrEmailExists))
+		h.errorResponse(ctx, http.StatusConflict, ErrEmailExists)
 		return
 	}
 
-	_, err = h.grpcClient.AuthService().Register(context.Background(), &pbu.RegisterRequest{
+	c, cancel = h.serviceContext(ctx, config.AuthService)
+	defer cancel()
+
+	_, err = h.grpcClient.AuthService().Register(c, &pbu.RegisterRequest{
 		Email:     req.Email,
 		Password:  req.Password,
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
 	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to register user")
 		return
 	}
 
@@ -70,43 +81,54 @@ func (h *handlerV1) Register(ctx *gin.Context) {
 // @Success 201 {object} models.AuthResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 func (h *handlerV1) Verify(ctx *gin.Context) {
 
 	var req models.VerifyRequest
 
 	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if h.checkLockout(ctx, attemptVerify, req.Email) {
 		return
 	}
 
-	result, err := h.grpcClient.AuthService().Verify(context.Background(), &pbu.VerifyRequest{
+	c, cancel := h.serviceContext(ctx, config.AuthService)
+	defer cancel()
+
+	result, err := h.grpcClient.AuthService().Verify(c, &pbu.VerifyRequest{
 		Email: req.Email,
 		Code:  req.Code,
 	})
 	if err != nil {
-		s, _ := status.FromError(err)
-		if s.Message() == "incorrect_code" {
-			ctx.JSON(http.StatusBadRequest, errorResponse(ErrIncorrectCode))
-			return
-		} else if s.Message() == "code_expired" {
-			ctx.JSON(http.StatusBadRequest, errorResponse(ErrCodeExpired))
-			return
-		} else {
-			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-			return
+		if isFailedAttempt(err) {
+			h.registerFailedAttempt(ctx, attemptVerify, req.Email)
 		}
+		h.grpcErrorResponse(ctx, err, "failed to verify user")
+		return
+	}
+
+	h.resetFailedAttempts(ctx, attemptVerify, req.Email)
+
+	refreshToken, err := h.issueRefreshToken("", result.Id, result.Email, result.Type)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
+		return
 	}
 
-	ctx.JSON(http.StatusCreated, &pbu.AuthResponse{
-		Id:          result.Id,
-		FirstName:   result.FirstName,
-		LastName:    result.LastName,
-		Email:       result.Email,
-		Username:    result.Username,
-		Type:        result.Type,
-		CreatedAt:   result.CreatedAt,
-		AccessToken: result.AccessToken,
+	ctx.JSON(http.StatusCreated, models.AuthResponse{
+		ID:           result.Id,
+		FirstName:    result.FirstName,
+		LastName:     result.LastName,
+		Email:        result.Email,
+		Username:     result.Username,
+		Type:         result.Type,
+		CreatedAt:    result.CreatedAt,
+		AccessToken:  result.AccessToken,
+		RefreshToken: refreshToken,
 	})
 }
 
@@ -120,39 +142,57 @@ func (h *handlerV1) Verify(ctx *gin.Context) {
 // @Success 201 {object} models.AuthResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 func (h *handlerV1) Login(ctx *gin.Context) {
 
 	var req models.LoginRequest
 
 	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	result, err := h.grpcClient.AuthService().Login(context.Background(), &pbu.LoginRequest{
+	if h.checkLockout(ctx, attemptLogin, req.Email) {
+		return
+	}
+
+	c, cancel := h.serviceContext(ctx, config.AuthService)
+	defer cancel()
+
+	result, err := h.grpcClient.AuthService().Login(c, &pbu.LoginRequest{
 		Email:    req.Email,
 		Password: req.Password,
 	})
 	if err != nil {
-		s, _ := status.FromError(err)
-		if s.Code() == codes.NotFound || s.Message() == "incorrect_password" {
-			ctx.JSON(http.StatusBadRequest, errorResponse(ErrWrongEmailOrPass))
+		if isFailedAttempt(err) {
+			h.registerFailedAttempt(ctx, attemptLogin, req.Email)
+		}
+		if isGrpcCode(err, codes.NotFound) {
+			h.errorResponse(ctx, http.StatusBadRequest, ErrWrongEmailOrPass)
 			return
 		}
+		h.grpcErrorResponse(ctx, err, "failed to login user")
+		return
+	}
 
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	h.resetFailedAttempts(ctx, attemptLogin, req.Email)
+
+	refreshToken, err := h.issueRefreshToken("", result.Id, result.Email, result.Type)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
 	ctx.JSON(http.StatusCreated, models.AuthResponse{
-		ID:          result.Id,
-		FirstName:   result.FirstName,
-		LastName:    result.LastName,
-		Email:       result.Email,
-		Type:        result.Type,
-		CreatedAt:   result.CreatedAt,
-		AccessToken: result.AccessToken,
+		ID:           result.Id,
+		FirstName:    result.FirstName,
+		LastName:     result.LastName,
+		Email:        result.Email,
+		Type:         result.Type,
+		CreatedAt:    result.CreatedAt,
+		AccessToken:  result.AccessToken,
+		RefreshToken: refreshToken,
 	})
 }
 
@@ -167,29 +207,40 @@ func (h *handlerV1) Login(ctx *gin.Context) {
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 func (h *handlerV1) ForgotPassword(ctx *gin.Context) {
 
 	var req models.ForgotPasswordRequest
 
 	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	_, err = h.grpcClient.UserService().GetByEmail(context.Background(), &pbu.EmailRequest{
+	c, cancel := h.serviceContext(ctx, config.UserService)
+	defer cancel()
+
+	_, err = h.grpcClient.UserService().GetByEmail(c, &pbu.EmailRequest{
 		Email: req.Email,
 	})
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, errorResponse(ErrWrongEmailOrPass))
+		if isGrpcCode(err, codes.NotFound) {
+			h.errorResponse(ctx, http.StatusNotFound, ErrWrongEmailOrPass)
+			return
+		}
+		h.grpcErrorResponse(ctx, err, "failed to get user by email")
 		return
 	}
 
-	_, err = h.grpcClient.AuthService().ForgotPassword(context.Background(), &pbu.ForgotPasswordRequest{
+	c, cancel = h.serviceContext(ctx, config.AuthService)
+	defer cancel()
+
+	_, err = h.grpcClient.AuthService().ForgotPassword(c, &pbu.ForgotPasswordRequest{
 		Email: req.Email,
 	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to send forgot password code")
 		return
 	}
 
@@ -209,34 +260,54 @@ func (h *handlerV1) ForgotPassword(ctx *gin.Context) {
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 func (h *handlerV1) VerifyForgotPassword(ctx *gin.Context) {
 
 	var req models.VerifyRequest
 
 	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	result, err := h.grpcClient.AuthService().VerifyForgotPassword(context.Background(), &pbu.VerifyRequest{
+	if h.checkLockout(ctx, attemptVerifyForgotPassword, req.Email) {
+		return
+	}
+
+	c, cancel := h.serviceContext(ctx, config.AuthService)
+	defer cancel()
+
+	result, err := h.grpcClient.AuthService().VerifyForgotPassword(c, &pbu.VerifyRequest{
 		Email: req.Email,
 		Code:  req.Code,
 	})
 	if err != nil {
-		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		if isFailedAttempt(err) {
+			h.registerFailedAttempt(ctx, attemptVerifyForgotPassword, req.Email)
+		}
+		h.grpcErrorResponse(ctx, err, "failed to verify forgot password")
+		return
+	}
+
+	h.resetFailedAttempts(ctx, attemptVerifyForgotPassword, req.Email)
+
+	refreshToken, err := h.issueRefreshToken("", result.Id, result.Email, result.Type)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
 	ctx.JSON(http.StatusCreated, models.AuthResponse{
-		ID:          result.Id,
-		FirstName:   result.FirstName,
-		LastName:    result.LastName,
-		Email:       result.Email,
-		Username:    result.Username,
-		Type:        result.Type,
-		CreatedAt:   result.CreatedAt,
-		AccessToken: result.AccessToken,
+		ID:           result.Id,
+		FirstName:    result.FirstName,
+		LastName:     result.LastName,
+		Email:        result.Email,
+		Username:     result.Username,
+		Type:         result.Type,
+		CreatedAt:    result.CreatedAt,
+		AccessToken:  result.AccessToken,
+		RefreshToken: refreshToken,
 	})
 }
 
@@ -257,27 +328,30 @@ func (h *handlerV1) UpdatePassword(ctx *gin.Context) {
 
 	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
 	if !validatePassword(req.Password) {
-		ctx.JSON(http.StatusBadRequest, errorResponse(ErrWeakPassword))
+		h.errorResponse(ctx, http.StatusBadRequest, ErrWeakPassword)
 		return
 	}
 
 	payload, err := h.GetAuthPayload(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
-	_, err = h.grpcClient.UserService().UpdatePassword(context.Background(), &pbu.UpdatePasswordRequest{
+	c, cancel := h.serviceContext(ctx, config.UserService)
+	defer cancel()
+
+	_, err = h.grpcClient.UserService().UpdatePassword(c, &pbu.UpdatePasswordRequest{
 		UserId:   payload.UserID,
 		Password: req.Password,
 	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to update password")
 		return
 	}
 
@@ -302,3 +376,190 @@ func validatePassword(password string) bool {
 	}
 	return capitalLetter && smallLetter && number && symbol
 }
+
+// @Router /auth/refresh [post]
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token and refresh token. Every refresh token can be used once; presenting it again revokes the whole session. Only available when the gateway verifies tokens and permissions locally, 501 otherwise.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param data body models.RefreshTokenRequest true "Data"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 501 {object} models.ErrorResponse
+func (h *handlerV1) RefreshToken(ctx *gin.Context) {
+	if h.tokenMaker == nil {
+		h.errorResponse(ctx, http.StatusNotImplemented, ErrRefreshDisabled)
+		return
+	}
+
+	var req models.RefreshTokenRequest
+
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	hash := token.Hash(req.RefreshToken)
+
+	old, err := h.storage.RefreshToken().Get(hash)
+	if errors.Is(err, repo.ErrNotFound) {
+		h.errorResponse(ctx, http.StatusUnauthorized, ErrRefreshToken)
+		return
+	}
+	if err != nil {
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !time.Now().Before(old.ExpiresAt) {
+		h.errorResponse(ctx, http.StatusUnauthorized, ErrRefreshToken)
+		return
+	}
+
+	c, cancel := h.serviceContext(ctx, config.UserService)
+	defer cancel()
+
+	// The user is fetched again so deleted users lose their sessions and
+	// role changes reach the new access token. This happens before the
+	// token is used, so a failed call leaves it valid for a retry.
+	user, err := h.grpcClient.UserService().Get(c, &pbu.GetUserRequest{Id: old.UserID})
+	if err != nil {
+		if isGrpcCode(err, codes.NotFound) {
+			h.revokeRefreshTokens(ctx, old.FamilyID)
+			h.errorResponse(ctx, http.StatusUnauthorized, ErrRefreshToken)
+			return
+		}
+		h.grpcErrorResponse(ctx, err, "failed to get user")
+		return
+	}
+
+	_, err = h.storage.RefreshToken().Use(hash)
+	if errors.Is(err, repo.ErrTokenReused) {
+		h.log(ctx).WithField("user_id", old.UserID).Warn("refresh token reuse detected, revoking session")
+		h.revokeRefreshTokens(ctx, old.FamilyID)
+		h.errorResponse(ctx, http.StatusUnauthorized, ErrRefreshTokenReuse)
+		return
+	}
+	if errors.Is(err, repo.ErrTokenRevoked) {
+		h.errorResponse(ctx, http.StatusUnauthorized, ErrSessionRevoked)
+		return
+	}
+	if errors.Is(err, repo.ErrNotFound) {
+		h.errorResponse(ctx, http.StatusUnauthorized, ErrRefreshToken)
+		return
+	}
+	if err != nil {
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	accessToken, _, err := h.tokenMaker.Create(token.Payload{
+		UserID:   user.Id,
+		Email:    user.Email,
+		UserType: user.Type,
+	})
+	if err != nil {
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	refreshToken, err := h.issueRefreshToken(old.FamilyID, user.Id, user.Email, user.Type)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	})
+}
+
+// @Router /auth/logout [post]
+// @Summary Logout user
+// @Description Revoke the refresh token and every token rotated from it. Access tokens stay valid until they expire.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param data body models.RefreshTokenRequest true "Data"
+// @Success 200 {object} models.OKResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) Logout(ctx *gin.Context) {
+	var req models.RefreshTokenRequest
+
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	// Unknown tokens are not reported, so logging out twice succeeds.
+	t, err := h.storage.RefreshToken().Get(token.Hash(req.RefreshToken))
+	if err != nil && !errors.Is(err, repo.ErrNotFound) {
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if t != nil {
+		err = h.storage.RefreshToken().RevokeFamily(t.FamilyID)
+		if err != nil {
+			h.errorResponse(ctx, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, models.OKResponse{
+		Message: "Success!",
+	})
+}
+
+// issueRefreshToken stores a new refresh token of familyID, starting a new
+// family when it is empty. It returns an empty token when refresh tokens
+// are disabled.
+func (h *handlerV1) issueRefreshToken(familyID string, userID int64, email, userType string) (string, error) {
+	if h.tokenMaker == nil {
+		return "", nil
+	}
+
+	if familyID == "" {
+		id, err := token.RandomString(16)
+		if err != nil {
+			return "", err
+		}
+		familyID = id
+	}
+
+	refreshToken, err := token.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = h.storage.RefreshToken().Create(&repo.RefreshToken{
+		Hash:      token.Hash(refreshToken),
+		FamilyID:  familyID,
+		UserID:    userID,
+		Email:     email,
+		UserType:  userType,
+		ExpiresAt: now.Add(h.cfg.RefreshTokenTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return refreshToken, nil
+}
+
+func (h *handlerV1) revokeRefreshTokens(ctx *gin.Context, familyID string) {
+	if err := h.storage.RefreshToken().RevokeFamily(familyID); err != nil {
+		h.log(ctx).WithError(err).Error("failed to revoke refresh tokens")
+	}
+}
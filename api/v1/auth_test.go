@@ -0,0 +1,184 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ibrat-muslim/blog_app_api_gateway/api/models"
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
+	pbu "github.com/ibrat-muslim/blog_app_api_gateway/genproto/user_service"
+	grpcPkg "github.com/ibrat-muslim/blog_app_api_gateway/pkg/grpc_client"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/token"
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeGrpcClient struct {
+	grpcPkg.GrpcClientI
+	user pbu.UserServiceClient
+}
+
+func (c *fakeGrpcClient) UserService() pbu.UserServiceClient {
+	return c.user
+}
+
+// fakeUserService answers Get with the errors in errs, one per call, and
+// with a user once they run out.
+type fakeUserService struct {
+	pbu.UserServiceClient
+	errs  []error
+	calls int
+}
+
+func (s *fakeUserService) Get(_ context.Context, req *pbu.GetUserRequest, _ ...grpc.CallOption) (*pbu.User, error) {
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return nil, err
+	}
+	return &pbu.User{Id: req.Id, Email: "user@example.com", Type: "user"}, nil
+}
+
+func newRefreshTestHandler(t *testing.T, users *fakeUserService) (*handlerV1, *gin.Engine) {
+	t.Helper()
+
+	maker, err := token.NewHMACMaker("secret", time.Minute)
+	if err != nil {
+		t.Fatalf("NewHMACMaker() error = %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	h := New(&HandlerV1Options{
+		Cfg: &config.Config{
+			RefreshTokenTTL: time.Hour,
+			Services: []config.Service{
+				{Name: config.UserService, Timeout: time.Second},
+			},
+		},
+		GrpcClient: &fakeGrpcClient{user: users},
+		Storage:    storage.NewStorageInMemory(),
+		Logger:     logger,
+		TokenMaker: maker,
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/refresh", h.RefreshToken)
+
+	return h, router
+}
+
+func refresh(t *testing.T, router *gin.Engine, refreshToken string) (int, models.TokenResponse, models.ErrorResponse) {
+	t.Helper()
+
+	body, _ := json.Marshal(models.RefreshTokenRequest{RefreshToken: refreshToken})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewReader(body)))
+
+	var (
+		tokens models.TokenResponse
+		errRes models.ErrorResponse
+	)
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &tokens); err != nil {
+			t.Fatalf("decode tokens: %v", err)
+		}
+	} else if err := json.Unmarshal(rec.Body.Bytes(), &errRes); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	return rec.Code, tokens, errRes
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	h, router := newRefreshTestHandler(t, &fakeUserService{})
+
+	first, err := h.issueRefreshToken("", 1, "user@example.com", "user")
+	if err != nil {
+		t.Fatalf("issueRefreshToken() error = %v", err)
+	}
+	// A second session of the same user must survive the revocation.
+	other, err := h.issueRefreshToken("", 1, "user@example.com", "user")
+	if err != nil {
+		t.Fatalf("issueRefreshToken() error = %v", err)
+	}
+
+	code, rotated, _ := refresh(t, router, first)
+	if code != http.StatusOK || rotated.RefreshToken == "" || rotated.AccessToken == "" {
+		t.Fatalf("refresh: status = %d, tokens = %+v", code, rotated)
+	}
+
+	code, _, errRes := refresh(t, router, first)
+	if code != http.StatusUnauthorized || errRes.Code != errorCodes[ErrRefreshTokenReuse] {
+		t.Fatalf("reuse: status = %d, code = %q", code, errRes.Code)
+	}
+
+	code, _, errRes = refresh(t, router, rotated.RefreshToken)
+	if code != http.StatusUnauthorized || errRes.Code != errorCodes[ErrSessionRevoked] {
+		t.Fatalf("rotated token after reuse: status = %d, code = %q", code, errRes.Code)
+	}
+
+	if code, _, _ = refresh(t, router, other); code != http.StatusOK {
+		t.Fatalf("other session: status = %d", code)
+	}
+}
+
+func TestRefreshTokenKeptOnUserServiceFailure(t *testing.T) {
+	users := &fakeUserService{errs: []error{
+		status.Error(codes.Unavailable, "down"),
+		status.Error(codes.DeadlineExceeded, "slow"),
+	}}
+	h, router := newRefreshTestHandler(t, users)
+
+	refreshToken, err := h.issueRefreshToken("", 1, "user@example.com", "user")
+	if err != nil {
+		t.Fatalf("issueRefreshToken() error = %v", err)
+	}
+
+	for _, want := range []int{http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		if code, _, _ := refresh(t, router, refreshToken); code != want {
+			t.Fatalf("status = %d, want %d", code, want)
+		}
+	}
+
+	// The retry goes through, since the failed calls did not use the token.
+	if code, _, _ := refresh(t, router, refreshToken); code != http.StatusOK {
+		t.Fatalf("retry: status = %d, want %d", code, http.StatusOK)
+	}
+	if users.calls != 3 {
+		t.Fatalf("user service calls = %d, want 3", users.calls)
+	}
+}
+
+func TestRefreshTokenDeletedUser(t *testing.T) {
+	h, router := newRefreshTestHandler(t, &fakeUserService{
+		errs: []error{status.Error(codes.NotFound, "user not found")},
+	})
+
+	refreshToken, err := h.issueRefreshToken("", 1, "user@example.com", "user")
+	if err != nil {
+		t.Fatalf("issueRefreshToken() error = %v", err)
+	}
+
+	code, _, errRes := refresh(t, router, refreshToken)
+	if code != http.StatusUnauthorized || errRes.Code != errorCodes[ErrRefreshToken] {
+		t.Fatalf("status = %d, code = %q", code, errRes.Code)
+	}
+
+	code, _, errRes = refresh(t, router, refreshToken)
+	if code != http.StatusUnauthorized || errRes.Code != errorCodes[ErrSessionRevoked] {
+		t.Fatalf("after delete: status = %d, code = %q", code, errRes.Code)
+	}
+}
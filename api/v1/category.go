@@ -1,15 +1,12 @@
 package v1
 
 import (
-	// "database/sql"
-	// "errors"
-	"context"
 	"net/http"
-
-	// "strconv"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ibrat-muslim/blog_app_api_gateway/api/models"
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
 	pbp "github.com/ibrat-muslim/blog_app_api_gateway/genproto/post_service"
 )
 
@@ -30,26 +27,24 @@ func (h *handlerV1) CreateCategory(ctx *gin.Context) {
 
 	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	resp, err := h.grpcClient.CategoryService().Create(context.Background(), &pbp.Category{
+	c, cancel := h.serviceContext(ctx, config.CategoryService)
+	defer cancel()
+
+	resp, err := h.grpcClient.CategoryService().Create(c, &pbp.Category{
 		Title: req.Title,
 	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to create category")
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, models.Category{
-		ID:        resp.Id,
-		Title:     resp.Title,
-		CreatedAt: resp.CreatedAt,
-	})
+	ctx.JSON(http.StatusCreated, parseCategoryToModel(resp))
 }
 
-/*
 // @Router /categories/{id} [get]
 // @Summary Get a category by id
 // @Description Get a category by id
@@ -65,25 +60,20 @@ func (h *handlerV1) GetCategory(ctx *gin.Context) {
 
 	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	resp, err := h.storage.Category().Get(id)
+	c, cancel := h.serviceContext(ctx, config.CategoryService)
+	defer cancel()
+
+	resp, err := h.grpcClient.CategoryService().Get(c, &pbp.GetCategoryRequest{Id: id})
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to get category")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, models.Category{
-		ID:        resp.ID,
-		Title:     resp.Title,
-		CreatedAt: resp.CreatedAt,
-	})
+	ctx.JSON(http.StatusOK, parseCategoryToModel(resp))
 }
 
 // @Router /categories [get]
@@ -99,35 +89,35 @@ func (h *handlerV1) GetCategory(ctx *gin.Context) {
 func (h *handlerV1) GetCategories(ctx *gin.Context) {
 	request, err := validateGetAllParamsRequest(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	result, err := h.storage.Category().GetAll(&repo.GetCategoriesParams{
+	c, cancel := h.serviceContext(ctx, config.CategoryService)
+	defer cancel()
+
+	result, err := h.grpcClient.CategoryService().GetAll(c, &pbp.GetAllCategoriesRequest{
 		Limit:  request.Limit,
 		Page:   request.Page,
 		Search: request.Search,
 	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to get all categories")
 		return
 	}
 
 	ctx.JSON(http.StatusOK, getCategoriesResponse(result))
 }
 
-func getCategoriesResponse(data *repo.GetCategoriesResult) *models.GetCategoriesResponse {
+func getCategoriesResponse(data *pbp.GetAllCategoriesResponse) *models.GetCategoriesResponse {
 	response := models.GetCategoriesResponse{
 		Categories: make([]*models.Category, 0),
 		Count:      data.Count,
 	}
 
-	for _, c := range data.Categories {
-		response.Categories = append(response.Categories, &models.Category{
-			ID:        c.ID,
-			Title:     c.Title,
-			CreatedAt: c.CreatedAt,
-		})
+	for _, category := range data.Categories {
+		c := parseCategoryToModel(category)
+		response.Categories = append(response.Categories, &c)
 	}
 
 	return &response
@@ -142,54 +132,39 @@ func getCategoriesResponse(data *repo.GetCategoriesResult) *models.GetCategories
 // @Produce json
 // @Param id path int true "ID"
 // @Param category body models.CreateCategoryRequest true "Category"
-// @Success 200 {object} models.OKResponse
+// @Success 200 {object} models.Category
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) UpdateCategory(ctx *gin.Context) {
-
-	payload, err := h.GetAuthPayload(ctx)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-
-	if payload.UserType != repo.UserTypeSuperAdmin {
-		ctx.JSON(http.StatusForbidden, errorResponse(ErrForbidden))
-		return
-	}
-
 	var req models.CreateCategoryRequest
 
-	err = ctx.ShouldBindJSON(&req)
+	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
 	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	err = h.storage.Category().Update(&repo.Category{
-		ID:    id,
+	c, cancel := h.serviceContext(ctx, config.CategoryService)
+	defer cancel()
+
+	resp, err := h.grpcClient.CategoryService().Update(c, &pbp.Category{
+		Id:    id,
 		Title: req.Title,
 	})
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to update category")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, models.OKResponse{
-		Message: "successfully updated",
-	})
+	ctx.JSON(http.StatusOK, parseCategoryToModel(resp))
 }
 
 // @Security ApiKeyAuth
@@ -206,31 +181,18 @@ func (h *handlerV1) UpdateCategory(ctx *gin.Context) {
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) DeleteCategory(ctx *gin.Context) {
-
-	payload, err := h.GetAuthPayload(ctx)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-
-	if payload.UserType != repo.UserTypeSuperAdmin {
-		ctx.JSON(http.StatusForbidden, errorResponse(ErrForbidden))
-		return
-	}
-
 	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	err = h.storage.Category().Delete(id)
+	c, cancel := h.serviceContext(ctx, config.CategoryService)
+	defer cancel()
+
+	_, err = h.grpcClient.CategoryService().Delete(c, &pbp.GetCategoryRequest{Id: id})
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to delete category")
 		return
 	}
 
@@ -238,4 +200,11 @@ func (h *handlerV1) DeleteCategory(ctx *gin.Context) {
 		Message: "successfully deleted",
 	})
 }
-*/
+
+func parseCategoryToModel(category *pbp.Category) models.Category {
+	return models.Category{
+		ID:        category.Id,
+		Title:     category.Title,
+		CreatedAt: category.CreatedAt,
+	}
+}
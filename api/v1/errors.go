@@ -0,0 +1,216 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ibrat-muslim/blog_app_api_gateway/api/models"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/requestid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Stable, machine-readable error codes returned in models.ErrorResponse.
+const (
+	ErrorCodeInvalidArgument    = "invalid_argument"
+	ErrorCodeUnauthenticated    = "unauthenticated"
+	ErrorCodePermissionDenied   = "permission_denied"
+	ErrorCodeNotFound           = "not_found"
+	ErrorCodeAlreadyExists      = "already_exists"
+	ErrorCodeFailedPrecondition = "failed_precondition"
+	ErrorCodeAborted            = "aborted"
+	ErrorCodeOutOfRange         = "out_of_range"
+	ErrorCodeResourceExhausted  = "resource_exhausted"
+	ErrorCodeCanceled           = "canceled"
+	ErrorCodeInternal           = "internal"
+	ErrorCodeUnknown            = "unknown"
+	ErrorCodeDataLoss           = "data_loss"
+	ErrorCodeUnimplemented      = "unimplemented"
+	ErrorCodeUnavailable        = "unavailable"
+	ErrorCodeDeadlineExceeded   = "deadline_exceeded"
+)
+
+type httpError struct {
+	status int
+	code   string
+}
+
+// grpcErrors maps every gRPC status code to the HTTP status and error code
+// returned to clients.
+var grpcErrors = map[codes.Code]httpError{
+	codes.Canceled:           {StatusClientClosedRequest, ErrorCodeCanceled},
+	codes.Unknown:            {http.StatusInternalServerError, ErrorCodeUnknown},
+	codes.InvalidArgument:    {http.StatusBadRequest, ErrorCodeInvalidArgument},
+	codes.DeadlineExceeded:   {http.StatusGatewayTimeout, ErrorCodeDeadlineExceeded},
+	codes.NotFound:           {http.StatusNotFound, ErrorCodeNotFound},
+	codes.AlreadyExists:      {http.StatusConflict, ErrorCodeAlreadyExists},
+	codes.PermissionDenied:   {http.StatusForbidden, ErrorCodePermissionDenied},
+	codes.ResourceExhausted:  {http.StatusTooManyRequests, ErrorCodeResourceExhausted},
+	codes.FailedPrecondition: {http.StatusBadRequest, ErrorCodeFailedPrecondition},
+	codes.Aborted:            {http.StatusConflict, ErrorCodeAborted},
+	codes.OutOfRange:         {http.StatusBadRequest, ErrorCodeOutOfRange},
+	codes.Unimplemented:      {http.StatusNotImplemented, ErrorCodeUnimplemented},
+	codes.Internal:           {http.StatusInternalServerError, ErrorCodeInternal},
+	codes.Unavailable:        {http.StatusServiceUnavailable, ErrorCodeUnavailable},
+	codes.DataLoss:           {http.StatusInternalServerError, ErrorCodeDataLoss},
+	codes.Unauthenticated:    {http.StatusUnauthorized, ErrorCodeUnauthenticated},
+}
+
+// statusErrorCodes is used for errors raised by the gateway itself that
+// have no dedicated code in errorCodes.
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:          ErrorCodeInvalidArgument,
+	http.StatusUnauthorized:        ErrorCodeUnauthenticated,
+	http.StatusForbidden:           ErrorCodePermissionDenied,
+	http.StatusNotFound:            ErrorCodeNotFound,
+	http.StatusConflict:            ErrorCodeAlreadyExists,
+	http.StatusTooManyRequests:     ErrorCodeResourceExhausted,
+	StatusClientClosedRequest:      ErrorCodeCanceled,
+	http.StatusNotImplemented:      ErrorCodeUnimplemented,
+	http.StatusServiceUnavailable:  ErrorCodeUnavailable,
+	http.StatusGatewayTimeout:      ErrorCodeDeadlineExceeded,
+	http.StatusInternalServerError: ErrorCodeInternal,
+}
+
+// errorCodes holds the codes of the gateway's own sentinel errors.
+var errorCodes = map[error]string{
+	ErrWrongEmailOrPass:  "wrong_email_or_password",
+	ErrUserNotVerified:   "user_not_verified",
+	ErrEmailExists:       "email_exists",
+	ErrIncorrectCode:     "incorrect_code",
+	ErrCodeExpired:       "code_expired",
+	ErrNotAllowed:        "not_allowed",
+	ErrForbidden:         "forbidden",
+	ErrWeakPassword:      "weak_password",
+	ErrInvalidSortByDate: "invalid_sort_by_date",
+	ErrInvalidLimit:      "invalid_limit",
+	ErrInvalidPage:       "invalid_page",
+	ErrInvalidUserID:     "invalid_user_id",
+	ErrInvalidCategoryID: "invalid_category_id",
+	ErrTooManyPosts:      "too_many_posts",
+	ErrMissingAuthHeader: "missing_authorization_header",
+	ErrInvalidToken:      "invalid_token",
+	ErrRateLimited:       "rate_limited",
+	ErrLockedOut:         "locked_out",
+	ErrPolicyDisabled:    "policy_disabled",
+	ErrRoleNotFound:      "role_not_found",
+	ErrRefreshDisabled:   "refresh_disabled",
+	ErrRefreshToken:      "invalid_refresh_token",
+	ErrRefreshTokenReuse: "refresh_token_reused",
+	ErrSessionRevoked:    "session_revoked",
+}
+
+// grpcMessageErrors translates the domain errors the user service reports
+// in status messages into the gateway's sentinel errors.
+var grpcMessageErrors = map[string]error{
+	"incorrect_code":     ErrIncorrectCode,
+	"code_expired":       ErrCodeExpired,
+	"incorrect_password": ErrWrongEmailOrPass,
+}
+
+// errorResponse aborts the request with an error raised by the gateway.
+// Server errors are logged and only their status text reaches the client.
+func (h *handlerV1) errorResponse(ctx *gin.Context, httpStatus int, err error) {
+	code, ok := sentinelErrorCode(err)
+	if !ok {
+		code = statusErrorCode(httpStatus)
+	}
+
+	message := err.Error()
+	if httpStatus >= http.StatusInternalServerError {
+		h.log(ctx).WithError(err).Error("request failed")
+		message = statusMessage(httpStatus)
+	}
+
+	if wantsProblemJSON(ctx) {
+		fieldErrors := bindingFieldErrors(err)
+		if len(fieldErrors) > 0 {
+			message = "request validation failed"
+		}
+		h.abortWithProblem(ctx, httpStatus, code, message, fieldErrors)
+		return
+	}
+
+	h.abortWithError(ctx, httpStatus, code, message)
+}
+
+// grpcErrorResponse aborts the request with the HTTP translation of a failed
+// gRPC call. The original error is logged with msg, at error level for
+// server errors and at warn level for client errors, like the access log.
+func (h *handlerV1) grpcErrorResponse(ctx *gin.Context, err error, msg string) {
+	s, _ := status.FromError(err)
+
+	e, ok := grpcErrors[s.Code()]
+	if !ok {
+		e = httpError{http.StatusInternalServerError, ErrorCodeInternal}
+	}
+
+	message := s.Message()
+	if e.status >= http.StatusInternalServerError || e.status == StatusClientClosedRequest {
+		message = statusMessage(e.status)
+	}
+
+	if domainErr, ok := grpcMessageErrors[s.Message()]; ok {
+		e = httpError{http.StatusBadRequest, errorCodes[domainErr]}
+		message = domainErr.Error()
+	}
+
+	entry := h.log(ctx).WithError(err)
+	if e.status >= http.StatusInternalServerError {
+		entry.Error(msg)
+	} else {
+		entry.Warn(msg)
+	}
+
+	if wantsProblemJSON(ctx) {
+		h.abortWithProblem(ctx, e.status, e.code, message, nil)
+		return
+	}
+
+	h.abortWithError(ctx, e.status, e.code, message)
+}
+
+func (h *handlerV1) abortWithError(ctx *gin.Context, httpStatus int, code, message string) {
+	ctx.AbortWithStatusJSON(httpStatus, models.ErrorResponse{
+		Error:     message,
+		Code:      code,
+		RequestID: requestid.FromContext(ctx.Request.Context()),
+	})
+}
+
+// isGrpcCode reports whether err is a gRPC status error with the given code.
+func isGrpcCode(err error, code codes.Code) bool {
+	s, ok := status.FromError(err)
+	return ok && s.Code() == code
+}
+
+// sentinelErrorCode looks up the code of a sentinel error. It cannot index
+// errorCodes directly, since some errors (like validator.ValidationErrors)
+// are not hashable.
+func sentinelErrorCode(err error) (string, bool) {
+	for sentinel, code := range errorCodes {
+		if errors.Is(err, sentinel) {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+func statusErrorCode(httpStatus int) string {
+	if code, ok := statusErrorCodes[httpStatus]; ok {
+		return code
+	}
+	if httpStatus >= http.StatusInternalServerError {
+		return ErrorCodeInternal
+	}
+	return ErrorCodeInvalidArgument
+}
+
+func statusMessage(httpStatus int) string {
+	if httpStatus == StatusClientClosedRequest {
+		return "client closed request"
+	}
+	return strings.ToLower(http.StatusText(httpStatus))
+}
@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGrpcErrorResponseLogLevel(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantLevel  logrus.Level
+	}{
+		{status.Error(codes.NotFound, "post not found"), http.StatusNotFound, logrus.WarnLevel},
+		{status.Error(codes.InvalidArgument, "bad title"), http.StatusBadRequest, logrus.WarnLevel},
+		{status.Error(codes.Unknown, "incorrect_password"), http.StatusBadRequest, logrus.WarnLevel},
+		{status.Error(codes.Canceled, "context canceled"), StatusClientClosedRequest, logrus.WarnLevel},
+		{status.Error(codes.Unavailable, "down"), http.StatusServiceUnavailable, logrus.ErrorLevel},
+		{status.Error(codes.Internal, "db failed"), http.StatusInternalServerError, logrus.ErrorLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(status.Code(tt.err).String(), func(t *testing.T) {
+			logger, hook := test.NewNullLogger()
+			h := New(&HandlerV1Options{Cfg: &config.Config{}, Logger: logger})
+
+			gin.SetMode(gin.TestMode)
+			rec := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(rec)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/posts/1", nil)
+
+			h.grpcErrorResponse(ctx, tt.err, "failed to get post")
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if len(hook.Entries) != 1 {
+				t.Fatalf("log entries = %d, want 1", len(hook.Entries))
+			}
+			if got := hook.LastEntry(); got.Level != tt.wantLevel || got.Message != "failed to get post" {
+				t.Fatalf("logged %q at %v, want %v", got.Message, got.Level, tt.wantLevel)
+			}
+		})
+	}
+}
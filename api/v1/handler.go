@@ -1,53 +1,124 @@
 package v1
 
 import (
+	"context"
 	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ibrat-muslim/blog_app_api_gateway/api/models"
 	"github.com/ibrat-muslim/blog_app_api_gateway/config"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/authcache"
 	grpcPkg "github.com/ibrat-muslim/blog_app_api_gateway/pkg/grpc_client"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/ratelimit"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/rbac"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/requestid"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/token"
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage"
 	"github.com/sirupsen/logrus"
 )
 
 var (
-	ErrWrongEmailOrPass = errors.New("wrong email or password")
-	ErrUserNotVerified  = errors.New("user not verified")
-	ErrEmailExists      = errors.New("email already exists")
-	ErrIncorrectCode    = errors.New("incorrect verification code")
-	ErrCodeExpired      = errors.New("verification code has been expired")
-	ErrNotAllowed       = errors.New("method not allowed")
-	ErrWeakPassword     = errors.New("password must contain at least one small letter, one capital letter, one number and one symbol")
+	ErrWrongEmailOrPass  = errors.New("wrong email or password")
+	ErrUserNotVerified   = errors.New("user not verified")
+	ErrEmailExists       = errors.New("email already exists")
+	ErrIncorrectCode     = errors.New("incorrect verification code")
+	ErrCodeExpired       = errors.New("verification code has been expired")
+	ErrNotAllowed        = errors.New("method not allowed")
+	ErrForbidden         = errors.New("forbidden")
+	ErrWeakPassword      = errors.New("password must contain at least one small letter, one capital letter, one number and one symbol")
+	ErrInvalidSortByDate = errors.New("sort_by_date must be either asc or desc")
+	ErrInvalidLimit      = errors.New("limit must be a positive number")
+	ErrInvalidPage       = errors.New("page must be a positive number")
+	ErrInvalidUserID     = errors.New("user_id must be a positive number")
+	ErrInvalidCategoryID = errors.New("category_id must be a positive number")
+	ErrTooManyPosts      = errors.New("too many posts match the search, please narrow it down")
+	ErrMissingAuthHeader = errors.New("authorization header is not provided")
+	ErrInvalidToken      = errors.New("invalid or expired access token")
+	ErrRateLimited       = errors.New("too many requests, please try again later")
+	ErrLockedOut         = errors.New("too many failed attempts, please try again later")
+	ErrPolicyDisabled    = errors.New("local rbac policy is not configured")
+	ErrRoleNotFound      = errors.New("role not found in policy")
+	ErrRefreshDisabled   = errors.New("refresh tokens are not enabled")
+	ErrRefreshToken      = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReuse = errors.New("refresh token has already been used, please login again")
+	ErrSessionRevoked    = errors.New("session has been revoked or expired, please login again")
 )
 
+const UserTypeSuperAdmin = "superadmin"
+
+// StatusClientClosedRequest is the non-standard status used when the client
+// goes away before the response is ready.
+const StatusClientClosedRequest = 499
+
 type handlerV1 struct {
-	cfg        *config.Config
-	grpcClient grpcPkg.GrpcClientI
-	logger     *logrus.Logger
+	cfg          *config.Config
+	grpcClient   grpcPkg.GrpcClientI
+	storage      storage.StorageI
+	logger       *logrus.Logger
+	rateLimits   ratelimit.Policies
+	ipRateLimits ratelimit.Policies
+	authCache    *authcache.Cache
+	verifier     *token.Verifier
+	policy       *rbac.Engine
+	tokenMaker   *token.Maker
 }
 
 type HandlerV1Options struct {
-	Cfg        *config.Config
-	GrpcClient grpcPkg.GrpcClientI
-	Logger     *logrus.Logger
+	Cfg          *config.Config
+	GrpcClient   grpcPkg.GrpcClientI
+	Storage      storage.StorageI
+	Logger       *logrus.Logger
+	RateLimits   ratelimit.Policies
+	IPRateLimits ratelimit.Policies
+	Verifier     *token.Verifier
+	Policy       *rbac.Engine
+	TokenMaker   *token.Maker
 }
 
 func New(options *HandlerV1Options) *handlerV1 {
 	return &handlerV1{
-		cfg:        options.Cfg,
-		grpcClient: options.GrpcClient,
-		logger:     options.Logger,
+		cfg:          options.Cfg,
+		grpcClient:   options.GrpcClient,
+		storage:      options.Storage,
+		logger:       options.Logger,
+		rateLimits:   options.RateLimits,
+		ipRateLimits: options.IPRateLimits,
+		authCache:    authcache.New(options.Cfg.AuthCacheTTL, options.Cfg.AuthCacheSize),
+		verifier:     options.Verifier,
+		policy:       options.Policy,
+		tokenMaker:   options.TokenMaker,
 	}
 }
 
-func errorResponse(err error) *models.ErrorResponse {
-	return &models.ErrorResponse{
-		Error: err.Error(),
-	}
+// log returns a logger entry tagged with the ID of the current request.
+func (h *handlerV1) log(ctx *gin.Context) *logrus.Entry {
+	return h.logger.WithField("request_id", requestid.FromContext(ctx.Request.Context()))
+}
+
+// serviceContext derives the context for a single call to service from the
+// incoming request, so the call stops when the client disconnects or the
+// configured timeout of the service expires.
+func (h *handlerV1) serviceContext(ctx *gin.Context, service string) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx.Request.Context(), h.cfg.Service(service).Timeout)
 }
 
 func validateGetAllParamsRequest(ctx *gin.Context) (*models.GetAllParamsRequest, error) {
+	limit, page, err := validatePagination(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.GetAllParamsRequest{
+		Limit:  limit,
+		Page:   page,
+		Search: ctx.Query("search"),
+	}, nil
+}
+
+// validatePagination parses the limit and page query parameters. Both must
+// be positive and fit the int32 fields of the gRPC requests.
+func validatePagination(ctx *gin.Context) (int32, int32, error) {
 	var (
 		limit int64 = 10
 		page  int64 = 1
@@ -55,22 +126,18 @@ func validateGetAllParamsRequest(ctx *gin.Context) (*models.GetAllParamsRequest,
 	)
 
 	if ctx.Query("limit") != "" {
-		limit, err = strconv.ParseInt(ctx.Query("limit"), 10, 64)
-		if err != nil {
-			return nil, err
+		limit, err = strconv.ParseInt(ctx.Query("limit"), 10, 32)
+		if err != nil || limit <= 0 {
+			return 0, 0, ErrInvalidLimit
 		}
 	}
 
 	if ctx.Query("page") != "" {
-		page, err = strconv.ParseInt(ctx.Query("page"), 10, 64)
-		if err != nil {
-			return nil, err
+		page, err = strconv.ParseInt(ctx.Query("page"), 10, 32)
+		if err != nil || page <= 0 {
+			return 0, 0, ErrInvalidPage
 		}
 	}
 
-	return &models.GetAllParamsRequest{
-		Limit:  int32(limit),
-		Page:   int32(page),
-		Search: ctx.Query("search"),
-	}, nil
+	return int32(limit), int32(page), nil
 }
@@ -0,0 +1,69 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ibrat-muslim/blog_app_api_gateway/api/models"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	healthStatusUp   = "up"
+	healthStatusDown = "down"
+	// healthStatusIdle is reported for optional services that have not been
+	// dialed yet. Required services are always probed.
+	healthStatusIdle = "idle"
+)
+
+// Healthz reports that the gateway process is up. It never checks downstream services.
+func (h *handlerV1) Healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, models.HealthResponse{
+		Status: healthStatusUp,
+	})
+}
+
+// Readyz reports whether the gateway can reach its downstream services.
+// It responds with 503 when any required service is down. The endpoint is
+// public, so targets and errors are only logged.
+func (h *handlerV1) Readyz(ctx *gin.Context) {
+	c, cancel := context.WithTimeout(ctx.Request.Context(), h.cfg.HealthCheckTimeout)
+	defer cancel()
+
+	response := models.HealthResponse{
+		Status:   healthStatusUp,
+		Services: make(map[string]models.ServiceHealth),
+	}
+	code := http.StatusOK
+
+	for _, service := range h.grpcClient.CheckHealth(c) {
+		serviceStatus := healthStatusUp
+		switch {
+		case !service.Dialed:
+			serviceStatus = healthStatusIdle
+		case !service.Healthy:
+			serviceStatus = healthStatusDown
+			h.log(ctx).WithFields(logrus.Fields{
+				"service":  service.Name,
+				"target":   service.Target,
+				"state":    service.State,
+				"required": service.Required,
+				"breaker":  service.Breaker,
+				"error":    service.Error,
+			}).Warn("service is down")
+
+			if service.Required {
+				response.Status = healthStatusDown
+				code = http.StatusServiceUnavailable
+			}
+		}
+
+		response.Services[service.Name] = models.ServiceHealth{
+			Status:  serviceStatus,
+			Breaker: service.Breaker,
+		}
+	}
+
+	ctx.JSON(code, response)
+}
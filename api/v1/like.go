@@ -0,0 +1,159 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ibrat-muslim/blog_app_api_gateway/api/models"
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
+	pbp "github.com/ibrat-muslim/blog_app_api_gateway/genproto/post_service"
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage/repo"
+)
+
+const (
+	likeStatusLike    = "like"
+	likeStatusDislike = "dislike"
+)
+
+// @Security ApiKeyAuth
+// @Router /posts/{id}/like [post]
+// @Summary Like or dislike a post
+// @Description Like or dislike a post
+// @Tags like
+// @Accept json
+// @Produce json
+// @Param id path int true "Post ID"
+// @Param like body models.CreateOrUpdateLikeRequest true "Like"
+// @Success 200 {object} models.Like
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) CreateOrUpdateLike(ctx *gin.Context) {
+	var req models.CreateOrUpdateLikeRequest
+
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	postID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	payload, err := h.GetAuthPayload(ctx)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	c, cancel := h.serviceContext(ctx, config.PostService)
+	defer cancel()
+
+	_, err = h.grpcClient.PostService().Get(c, &pbp.GetPostRequest{Id: postID})
+	if err != nil {
+		h.grpcErrorResponse(ctx, err, "failed to get post")
+		return
+	}
+
+	like, err := h.storage.Like().CreateOrUpdate(&repo.Like{
+		PostID: postID,
+		UserID: payload.UserID,
+		Status: req.Status == likeStatusLike,
+	})
+	if err != nil {
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, parseLikeToModel(like))
+}
+
+// @Security ApiKeyAuth
+// @Router /posts/{id}/like [delete]
+// @Summary Remove like or dislike from a post
+// @Description Remove like or dislike from a post
+// @Tags like
+// @Accept json
+// @Produce json
+// @Param id path int true "Post ID"
+// @Success 200 {object} models.OKResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) DeleteLike(ctx *gin.Context) {
+	postID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	payload, err := h.GetAuthPayload(ctx)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = h.storage.Like().Delete(payload.UserID, postID)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			h.errorResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.OKResponse{
+		Message: "successfully deleted",
+	})
+}
+
+// @Router /posts/{id}/likes [get]
+// @Summary Get likes and dislikes count of a post
+// @Description Get likes and dislikes count of a post
+// @Tags like
+// @Accept json
+// @Produce json
+// @Param id path int true "Post ID"
+// @Success 200 {object} models.PostLikeInfo
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) GetPostLikeInfo(ctx *gin.Context) {
+	postID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	likeInfo, err := h.storage.Like().GetLikesDislikesCount(postID)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.PostLikeInfo{
+		LikesCount:    likeInfo.LikesCount,
+		DislikesCount: likeInfo.DislikesCount,
+	})
+}
+
+func parseLikeToModel(like *repo.Like) models.Like {
+	likeStatus := likeStatusDislike
+	if like.Status {
+		likeStatus = likeStatusLike
+	}
+
+	return models.Like{
+		ID:        like.ID,
+		PostID:    like.PostID,
+		UserID:    like.UserID,
+		Status:    likeStatus,
+		CreatedAt: like.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,137 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage/repo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Actions whose failed attempts are tracked separately.
+const (
+	attemptLogin                = "login"
+	attemptVerify               = "verify"
+	attemptVerifyForgotPassword = "verify-forgot-password"
+)
+
+// maxBackoffShift keeps the exponential backoff from overflowing.
+const maxBackoffShift = 30
+
+// checkLockout aborts the request with ErrLockedOut if the email or the
+// client IP is currently locked out of action.
+func (h *handlerV1) checkLockout(ctx *gin.Context, action, email string) bool {
+	var lockedUntil time.Time
+
+	for _, key := range []string{emailAttemptKey(action, email), h.ipAttemptKey(ctx, action)} {
+		attempt, err := h.storage.LoginAttempt().Get(key)
+		if errors.Is(err, repo.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			h.log(ctx).WithError(err).Warn("failed to get login attempts")
+			continue
+		}
+
+		if attempt.LockedUntil.After(lockedUntil) {
+			lockedUntil = attempt.LockedUntil
+		}
+	}
+
+	retryAfter := time.Until(lockedUntil)
+	if retryAfter <= 0 {
+		return false
+	}
+
+	ctx.Header("Retry-After", strconv.FormatInt(ceilSeconds(retryAfter), 10))
+	h.errorResponse(ctx, http.StatusTooManyRequests, ErrLockedOut)
+	return true
+}
+
+// registerFailedAttempt counts a failed attempt against both the email and
+// the client IP. Each failure of an email delays the next attempt twice as
+// long as the previous one; reaching the limit locks it out for
+// LockoutDuration. The IP is only locked out once its larger limit is hit,
+// so users sharing an address are not slowed down by each other's typos.
+func (h *handlerV1) registerFailedAttempt(ctx *gin.Context, action, email string) {
+	now := time.Now()
+
+	attempt, err := h.storage.LoginAttempt().AddFailure(emailAttemptKey(action, email), h.cfg.LockoutWindow)
+	if err != nil {
+		h.log(ctx).WithError(err).Warn("failed to record login attempt")
+	} else if lockFor := h.emailLockDuration(attempt.Failures); lockFor > 0 {
+		h.lock(ctx, emailAttemptKey(action, email), now.Add(lockFor))
+	}
+
+	attempt, err = h.storage.LoginAttempt().AddFailure(h.ipAttemptKey(ctx, action), h.cfg.LockoutWindow)
+	if err != nil {
+		h.log(ctx).WithError(err).Warn("failed to record login attempt")
+	} else if attempt.Failures >= h.cfg.LockoutMaxAttemptsPerIP {
+		h.lock(ctx, h.ipAttemptKey(ctx, action), now.Add(h.cfg.LockoutDuration))
+	}
+}
+
+// resetFailedAttempts forgets the failures of an email after a successful
+// attempt. The IP counter is kept, since one valid account must not unlock
+// guessing against others.
+func (h *handlerV1) resetFailedAttempts(ctx *gin.Context, action, email string) {
+	if err := h.storage.LoginAttempt().Reset(emailAttemptKey(action, email)); err != nil {
+		h.log(ctx).WithError(err).Warn("failed to reset login attempts")
+	}
+}
+
+func (h *handlerV1) lock(ctx *gin.Context, key string, until time.Time) {
+	if err := h.storage.LoginAttempt().Lock(key, until); err != nil {
+		h.log(ctx).WithError(err).Warn("failed to lock out login attempts")
+	}
+}
+
+func (h *handlerV1) emailLockDuration(failures int64) time.Duration {
+	if failures >= h.cfg.LockoutMaxAttempts {
+		return h.cfg.LockoutDuration
+	}
+
+	shift := failures - 1
+	if shift > maxBackoffShift {
+		shift = maxBackoffShift
+	}
+
+	backoff := h.cfg.LockoutBackoff << shift
+	if backoff > h.cfg.LockoutDuration {
+		return h.cfg.LockoutDuration
+	}
+	return backoff
+}
+
+func (h *handlerV1) ipAttemptKey(ctx *gin.Context, action string) string {
+	return action + "|ip:" + clientIP(ctx)
+}
+
+func emailAttemptKey(action, email string) string {
+	return action + "|email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// isFailedAttempt reports whether err means the credentials or the code
+// were wrong. Unknown emails count too, so they can't be told apart from
+// existing ones by probing.
+func isFailedAttempt(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+
+	if s.Code() == codes.NotFound {
+		return true
+	}
+
+	switch s.Message() {
+	case "incorrect_password", "incorrect_code":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package v1
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage"
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage/memory"
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage/repo"
+	"github.com/sirupsen/logrus"
+)
+
+// lockoutStorage serves login attempts from memory.NewLoginAttempt and
+// everything else from the default in-memory storage.
+type lockoutStorage struct {
+	storage.StorageI
+	attempts repo.LoginAttemptStorageI
+}
+
+func (s *lockoutStorage) LoginAttempt() repo.LoginAttemptStorageI {
+	return s.attempts
+}
+
+func newLockoutTestHandler(cfg config.Config) (*handlerV1, repo.LoginAttemptStorageI) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	attempts := memory.NewLoginAttempt()
+	h := New(&HandlerV1Options{
+		Cfg:     &cfg,
+		Storage: &lockoutStorage{StorageI: storage.NewStorageInMemory(), attempts: attempts},
+		Logger:  logger,
+	})
+	return h, attempts
+}
+
+func lockoutTestConfig() config.Config {
+	return config.Config{
+		LockoutMaxAttempts:      5,
+		LockoutMaxAttemptsPerIP: 8,
+		LockoutBackoff:          time.Second,
+		LockoutDuration:         15 * time.Minute,
+		LockoutWindow:           15 * time.Minute,
+	}
+}
+
+func lockoutTestContext(ip string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/auth/login", nil)
+	ctx.Request.RemoteAddr = ip + ":1234"
+	return ctx
+}
+
+func TestEmailLockDuration(t *testing.T) {
+	h, _ := newLockoutTestHandler(lockoutTestConfig())
+
+	want := []time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 8 * time.Second,
+		5: 15 * time.Minute,
+		6: 15 * time.Minute,
+	}
+	for failures := 1; failures < len(want); failures++ {
+		if got := h.emailLockDuration(int64(failures)); got != want[failures] {
+			t.Errorf("emailLockDuration(%d) = %v, want %v", failures, got, want[failures])
+		}
+	}
+
+	// The backoff never exceeds the lockout and does not overflow.
+	cfg := lockoutTestConfig()
+	cfg.LockoutMaxAttempts = 1000
+	cfg.LockoutBackoff = time.Minute
+	h, _ = newLockoutTestHandler(cfg)
+	for failures, want := range map[int64]time.Duration{
+		4:   8 * time.Minute,
+		5:   15 * time.Minute,
+		100: 15 * time.Minute,
+	} {
+		if got := h.emailLockDuration(failures); got != want {
+			t.Errorf("emailLockDuration(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
+
+func TestRegisterFailedAttemptLocksEmail(t *testing.T) {
+	h, attempts := newLockoutTestHandler(lockoutTestConfig())
+	key := emailAttemptKey(attemptLogin, "user@example.com")
+
+	for failures, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 15 * time.Minute} {
+		start := time.Now()
+		h.registerFailedAttempt(lockoutTestContext("203.0.113.7"), attemptLogin, " User@Example.com")
+
+		attempt, err := attempts.Get(key)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if attempt.Failures != int64(failures+1) {
+			t.Fatalf("failures = %d, want %d", attempt.Failures, failures+1)
+		}
+		if lockFor := attempt.LockedUntil.Sub(start); lockFor < want || lockFor > want+time.Second {
+			t.Fatalf("after %d failures locked for %v, want %v", failures+1, lockFor, want)
+		}
+	}
+
+	if !h.checkLockout(lockoutTestContext("198.51.100.1"), attemptLogin, "user@example.com") {
+		t.Fatal("locked email allowed from another IP")
+	}
+	if h.checkLockout(lockoutTestContext("203.0.113.7"), attemptVerify, "user@example.com") {
+		t.Fatal("lockout of login applied to verify")
+	}
+}
+
+func TestRegisterFailedAttemptLocksIP(t *testing.T) {
+	h, attempts := newLockoutTestHandler(lockoutTestConfig())
+	ipKey := h.ipAttemptKey(lockoutTestContext("203.0.113.7"), attemptLogin)
+
+	// Every email fails once, so only the IP counter reaches its limit.
+	emails := []string{"a@x.io", "b@x.io", "c@x.io", "d@x.io", "e@x.io", "f@x.io", "g@x.io"}
+	for _, email := range emails {
+		h.registerFailedAttempt(lockoutTestContext("203.0.113.7"), attemptLogin, email)
+	}
+
+	attempt, err := attempts.Get(ipKey)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if attempt.Failures != 7 || !attempt.LockedUntil.IsZero() {
+		t.Fatalf("below the IP limit: %+v", *attempt)
+	}
+
+	if h.checkLockout(lockoutTestContext("203.0.113.7"), attemptLogin, "new@x.io") {
+		t.Fatal("IP locked before its limit")
+	}
+
+	h.registerFailedAttempt(lockoutTestContext("203.0.113.7"), attemptLogin, "h@x.io")
+	if !h.checkLockout(lockoutTestContext("203.0.113.7"), attemptLogin, "new@x.io") {
+		t.Fatal("IP not locked at its limit")
+	}
+	if h.checkLockout(lockoutTestContext("198.51.100.1"), attemptLogin, "new@x.io") {
+		t.Fatal("lockout of one IP applied to another")
+	}
+}
+
+func TestResetFailedAttemptsKeepsIP(t *testing.T) {
+	h, attempts := newLockoutTestHandler(lockoutTestConfig())
+	ctx := lockoutTestContext("203.0.113.7")
+
+	h.registerFailedAttempt(ctx, attemptLogin, "user@example.com")
+	h.registerFailedAttempt(ctx, attemptLogin, "user@example.com")
+	h.resetFailedAttempts(ctx, attemptLogin, "user@example.com")
+
+	if _, err := attempts.Get(emailAttemptKey(attemptLogin, "user@example.com")); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("email attempts after reset: error = %v, want %v", err, repo.ErrNotFound)
+	}
+
+	attempt, err := attempts.Get(h.ipAttemptKey(ctx, attemptLogin))
+	if err != nil {
+		t.Fatalf("IP attempts after reset: error = %v", err)
+	}
+	if attempt.Failures != 2 {
+		t.Fatalf("IP failures = %d, want 2", attempt.Failures)
+	}
+}
@@ -1,17 +1,34 @@
 package v1
 
 import (
-	"context"
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
 	pbu "github.com/ibrat-muslim/blog_app_api_gateway/genproto/user_service"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/authcache"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/logger"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/ratelimit"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/token"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
 	authorizationHeaderKey  = "authorization"
 	authorizationPayloadKey = "authorization_payload"
+
+	// maxLoggedBodySize caps how much of a request body is kept for debug logs.
+	maxLoggedBodySize = 64 << 10
 )
 
 type Payload struct {
@@ -23,44 +40,102 @@ type Payload struct {
 	ExpiredAt string `json:"expired_at"`
 }
 
+// AuthMiddleware authenticates the caller and checks that they may perform
+// action on resource. In local auth mode the token is verified by the
+// gateway, and with a local RBAC policy the permission is decided by the
+// gateway too. The user service is only called for whatever is left.
 func (h *handlerV1) AuthMiddleware(resource, action string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		accessToken := ctx.GetHeader(authorizationHeaderKey)
 
 		if len(accessToken) == 0 {
-			err := errors.New("authorization header is not provided")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			h.errorResponse(ctx, http.StatusUnauthorized, ErrMissingAuthHeader)
 			return
 		}
 
-		payload, err := h.grpcClient.AuthService().VerifyToken(context.Background(), &pbu.VerifyTokenRequest{
-			AccessToken: accessToken,
-			Resource:    resource,
-			Action:      action,
-		})
-		if err != nil {
-			h.logger.WithError(err).Error("failed to verify token")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
-			return
+		var (
+			payload       *Payload
+			hasPermission bool
+		)
+
+		if h.verifier != nil {
+			localPayload, err := h.verifier.Verify(accessToken)
+			if err != nil {
+				h.log(ctx).WithError(err).Warn("invalid access token")
+				h.errorResponse(ctx, http.StatusUnauthorized, ErrInvalidToken)
+				return
+			}
+			payload = parseTokenPayload(localPayload)
+		}
+
+		if payload == nil || h.policy == nil {
+			c, cancel := h.serviceContext(ctx, config.AuthService)
+			defer cancel()
+
+			key := authcache.Key(accessToken, resource, action)
+			remotePayload, err := h.authCache.Get(key, func() (*pbu.AuthPayload, error) {
+				return h.grpcClient.AuthService().VerifyToken(c, &pbu.VerifyTokenRequest{
+					AccessToken: accessToken,
+					Resource:    resource,
+					Action:      action,
+				})
+			})
+			if err != nil {
+				switch status.Code(err) {
+				case codes.Canceled, codes.DeadlineExceeded, codes.Unavailable:
+					h.grpcErrorResponse(ctx, err, "failed to verify token")
+				default:
+					h.log(ctx).WithError(err).Warn("invalid access token")
+					h.errorResponse(ctx, http.StatusUnauthorized, ErrInvalidToken)
+				}
+				return
+			}
+
+			hasPermission = remotePayload.HasPermission
+			if payload == nil {
+				payload = &Payload{
+					ID:        remotePayload.Id,
+					UserID:    remotePayload.UserId,
+					Email:     remotePayload.Email,
+					UserType:  remotePayload.UserType,
+					IssuedAt:  remotePayload.IssuedAt,
+					ExpiredAt: remotePayload.ExpiredAt,
+				}
+			}
 		}
 
-		if !payload.HasPermission {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(ErrNotAllowed))
+		if h.policy != nil {
+			hasPermission = h.policy.Allowed(payload.UserType, resource, action)
+		}
+
+		if !hasPermission {
+			h.errorResponse(ctx, http.StatusForbidden, ErrNotAllowed)
 			return
 		}
 
-		ctx.Set(authorizationPayloadKey, &Payload{
-			ID:        payload.Id,
-			UserID:    payload.UserId,
-			Email:     payload.Email,
-			UserType:  payload.UserType,
-			IssuedAt:  payload.IssuedAt,
-			ExpiredAt: payload.ExpiredAt,
-		})
+		ctx.Set(authorizationPayloadKey, payload)
 		ctx.Next()
 	}
 }
 
+func parseTokenPayload(p *token.Payload) *Payload {
+	return &Payload{
+		ID:        p.ID,
+		UserID:    p.UserID,
+		Email:     p.Email,
+		UserType:  p.UserType,
+		IssuedAt:  formatTime(p.IssuedAt),
+		ExpiredAt: formatTime(p.ExpiredAt),
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
 func (m *handlerV1) GetAuthPayload(ctx *gin.Context) (*Payload, error) {
 	i, exists := ctx.Get(authorizationPayloadKey)
 	if !exists {
@@ -73,3 +148,137 @@ func (m *handlerV1) GetAuthPayload(ctx *gin.Context) (*Payload, error) {
 	}
 	return payload, nil
 }
+
+// AccessLogMiddleware writes one structured entry per request. Server errors
+// are logged at error level and client errors at warn level. At debug level
+// the redacted request headers and body are included as well.
+func (h *handlerV1) AccessLogMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		debugEnabled := h.logger.IsLevelEnabled(logrus.DebugLevel)
+
+		var body []byte
+		if debugEnabled && ctx.Request.Body != nil {
+			body, _ = io.ReadAll(io.LimitReader(ctx.Request.Body, maxLoggedBodySize))
+			ctx.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), ctx.Request.Body))
+		}
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		entry := h.log(ctx).WithFields(logrus.Fields{
+			"method":     ctx.Request.Method,
+			"route":      ctx.FullPath(),
+			"path":       ctx.Request.URL.Path,
+			"status":     status,
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes":      ctx.Writer.Size(),
+			"client_ip":  ctx.ClientIP(),
+			"user_agent": ctx.Request.UserAgent(),
+		})
+
+		if payload, err := h.GetAuthPayload(ctx); err == nil {
+			entry = entry.WithField("user_id", payload.UserID)
+		}
+
+		if debugEnabled {
+			entry = entry.WithField("headers", logger.RedactHeaders(ctx.Request.Header))
+			if len(body) > 0 {
+				entry = entry.WithField("body", logger.RedactJSON(body))
+			}
+		}
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			entry.Error("request completed")
+		case status >= http.StatusBadRequest:
+			entry.Warn("request completed")
+		default:
+			entry.Info("request completed")
+		}
+	}
+}
+
+// RecoveryMiddleware turns a panic in a handler into a logged 500 response.
+func (h *handlerV1) RecoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(ctx *gin.Context, recovered interface{}) {
+		h.log(ctx).WithField("stack", string(debug.Stack())).Error("panic recovered")
+		h.errorResponse(ctx, http.StatusInternalServerError, fmt.Errorf("panic: %v", recovered))
+	})
+}
+
+// RateLimitMiddleware enforces the IP policy of the matched route per client
+// IP. It runs before AuthMiddleware, so requests carrying invalid tokens are
+// limited before they reach the user service. Users behind one NAT share
+// this budget, so its default is looser than the per-user one.
+func (h *handlerV1) RateLimitMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		h.rateLimit(ctx, h.ipRateLimits, "ip:"+clientIP(ctx))
+	}
+}
+
+// UserRateLimitMiddleware enforces the user policy of the matched route per
+// authenticated user. It must come after AuthMiddleware.
+func (h *handlerV1) UserRateLimitMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload, err := h.GetAuthPayload(ctx)
+		if err != nil {
+			ctx.Next()
+			return
+		}
+
+		h.rateLimit(ctx, h.rateLimits, "user:"+strconv.FormatInt(payload.UserID, 10))
+	}
+}
+
+func (h *handlerV1) rateLimit(ctx *gin.Context, policies ratelimit.Policies, subject string) {
+	route, limit, ok := policies.Lookup(ctx.Request.Method, ctx.FullPath())
+	if !ok {
+		ctx.Next()
+		return
+	}
+
+	result, err := h.storage.RateLimit().Take(route+"|"+subject, limit.Requests, limit.Period)
+	if err != nil {
+		// Fail open: an unavailable limiter backend must not take the API down.
+		h.log(ctx).WithError(err).Warn("failed to apply rate limit")
+		ctx.Next()
+		return
+	}
+
+	ctx.Header("X-RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+	ctx.Header("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+	ctx.Header("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(result.ResetAfter), 10))
+
+	if !result.Allowed {
+		ctx.Header("Retry-After", strconv.FormatInt(ceilSeconds(result.RetryAfter), 10))
+		h.errorResponse(ctx, http.StatusTooManyRequests, ErrRateLimited)
+		return
+	}
+
+	ctx.Next()
+}
+
+// clientIP is the address rate limits and lockouts are keyed on. The router
+// only honors X-Forwarded-For from TRUSTED_PROXIES, so without them this is
+// the peer address and rotating the header does not reset any counter.
+func clientIP(ctx *gin.Context) string {
+	return ctx.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// HSTSMiddleware tells browsers to use HTTPS only. The header is sent on
+// TLS connections alone, as browsers ignore it over plain HTTP.
+func (h *handlerV1) HSTSMiddleware() gin.HandlerFunc {
+	value := "max-age=" + strconv.FormatInt(int64(h.cfg.HSTSMaxAge.Seconds()), 10)
+
+	return func(ctx *gin.Context) {
+		if ctx.Request.TLS != nil && h.cfg.HSTSMaxAge > 0 {
+			ctx.Header("Strict-Transport-Security", value)
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,87 @@
+package v1
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/ratelimit"
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRateLimitPolicies(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	h := New(&HandlerV1Options{
+		Cfg:     &config.Config{},
+		Storage: storage.NewStorageInMemory(),
+		Logger:  logger,
+		RateLimits: ratelimit.Policies{
+			ratelimit.DefaultRoute: {Requests: 2, Period: time.Hour},
+		},
+		IPRateLimits: ratelimit.Policies{
+			"POST /v1/auth/login":  {Requests: 1, Period: time.Hour},
+			ratelimit.DefaultRoute: {Requests: 5, Period: time.Hour},
+		},
+	})
+
+	// fakeAuth stands in for AuthMiddleware, taking the user from a header.
+	fakeAuth := func(ctx *gin.Context) {
+		ctx.Set(authorizationPayloadKey, &Payload{UserID: int64(len(ctx.GetHeader("X-User")))})
+		ctx.Next()
+	}
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	apiV1 := router.Group("/v1")
+	apiV1.Use(h.RateLimitMiddleware())
+	apiV1.POST("/auth/login", ok)
+	apiV1.POST("/posts", fakeAuth, h.UserRateLimitMiddleware(), ok)
+
+	do := func(method, path, user string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Login is limited by its own IP policy.
+	if code := do(http.MethodPost, "/v1/auth/login", ""); code != http.StatusOK {
+		t.Fatalf("login: status = %d", code)
+	}
+	if code := do(http.MethodPost, "/v1/auth/login", ""); code != http.StatusTooManyRequests {
+		t.Fatalf("second login: status = %d", code)
+	}
+
+	// Each user behind the IP gets the per-user budget, until the looser
+	// IP budget runs out.
+	for _, user := range []string{"a", "a", "bb", "bb", "ccc"} {
+		if code := do(http.MethodPost, "/v1/posts", user); code != http.StatusOK {
+			t.Fatalf("user %q: status = %d", user, code)
+		}
+	}
+	if code := do(http.MethodPost, "/v1/posts", "dddd"); code != http.StatusTooManyRequests {
+		t.Fatalf("after the IP budget: status = %d", code)
+	}
+
+	// A user over their own limit is stopped even with IP budget left.
+	req := httptest.NewRequest(http.MethodPost, "/v1/posts", nil)
+	req.RemoteAddr = "198.51.100.1:1234"
+	req.Header.Set("X-User", "a")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("user over limit: status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,50 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ibrat-muslim/blog_app_api_gateway/api/models"
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
+	pbn "github.com/ibrat-muslim/blog_app_api_gateway/genproto/notification_service"
+)
+
+// @Security ApiKeyAuth
+// @Router /notifications/email [post]
+// @Summary Send an email
+// @Description Send a templated email through the notification service. Requires the notifications send-email permission.
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param data body models.SendEmailRequest true "Data"
+// @Success 200 {object} models.OKResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) SendEmail(ctx *gin.Context) {
+	var req models.SendEmailRequest
+
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	c, cancel := h.serviceContext(ctx, config.NotificationService)
+	defer cancel()
+
+	_, err = h.grpcClient.NotificationService().SendEmail(c, &pbn.SendEmailRequest{
+		To:      req.To,
+		Type:    req.Type,
+		Subject: req.Subject,
+		Body:    req.Body,
+	})
+	if err != nil {
+		h.grpcErrorResponse(ctx, err, "failed to send email")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.OKResponse{
+		Message: "Email has been sent",
+	})
+}
@@ -0,0 +1,93 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
+	pbp "github.com/ibrat-muslim/blog_app_api_gateway/genproto/post_service"
+	"google.golang.org/grpc/status"
+)
+
+const resourceOwnerKey = "resource_owner_id"
+
+// ownerFunc returns the ID of the user owning the resource addressed by the
+// request.
+type ownerFunc func(ctx *gin.Context) (int64, error)
+
+// OwnershipMiddleware lets the request through only if the caller owns the
+// addressed resource or is a superadmin. It must come after AuthMiddleware.
+// resource is one of "users" or "posts". Resources without an owner, like
+// categories, are left to the permission check of AuthMiddleware.
+func (h *handlerV1) OwnershipMiddleware(resource string) gin.HandlerFunc {
+	owners := map[string]ownerFunc{
+		"users": h.userOwner,
+		"posts": h.postOwner,
+	}
+
+	owner, ok := owners[resource]
+	if !ok {
+		panic(fmt.Sprintf("no owner lookup for resource %q", resource))
+	}
+
+	return func(ctx *gin.Context) {
+		payload, err := h.GetAuthPayload(ctx)
+		if err != nil {
+			h.errorResponse(ctx, http.StatusInternalServerError, err)
+			return
+		}
+
+		ownerID, err := owner(ctx)
+		if err != nil {
+			if _, ok := status.FromError(err); ok {
+				h.grpcErrorResponse(ctx, err, "failed to get resource owner")
+				return
+			}
+			h.errorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+
+		if ownerID != payload.UserID && payload.UserType != UserTypeSuperAdmin {
+			h.errorResponse(ctx, http.StatusForbidden, ErrForbidden)
+			return
+		}
+
+		ctx.Set(resourceOwnerKey, ownerID)
+		ctx.Next()
+	}
+}
+
+// resourceOwner returns the owner found by OwnershipMiddleware, or fallback
+// when the middleware did not run.
+func (h *handlerV1) resourceOwner(ctx *gin.Context, fallback int64) int64 {
+	if ownerID, ok := ctx.Get(resourceOwnerKey); ok {
+		if id, ok := ownerID.(int64); ok {
+			return id
+		}
+	}
+	return fallback
+}
+
+// userOwner treats every user as the owner of their own profile.
+func (h *handlerV1) userOwner(ctx *gin.Context) (int64, error) {
+	return strconv.ParseInt(ctx.Param("id"), 10, 64)
+}
+
+func (h *handlerV1) postOwner(ctx *gin.Context) (int64, error) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	c, cancel := h.serviceContext(ctx, config.PostService)
+	defer cancel()
+
+	post, err := h.grpcClient.PostService().Get(c, &pbp.GetPostRequest{Id: id})
+	if err != nil {
+		return 0, err
+	}
+
+	return post.UserId, nil
+}
@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ibrat-muslim/blog_app_api_gateway/api/models"
+)
+
+// @Security ApiKeyAuth
+// @Router /admin/policies/{role} [get]
+// @Summary Get role policy
+// @Description Get the effective local RBAC rules of a role. Requires the policies get permission.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param role path string true "Role"
+// @Success 200 {object} models.RolePolicy
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 501 {object} models.ErrorResponse
+func (h *handlerV1) GetRolePolicy(ctx *gin.Context) {
+	if h.policy == nil {
+		h.errorResponse(ctx, http.StatusNotImplemented, ErrPolicyDisabled)
+		return
+	}
+
+	role := ctx.Param("role")
+
+	rules, ok := h.policy.Rules(role)
+	if !ok {
+		h.errorResponse(ctx, http.StatusNotFound, ErrRoleNotFound)
+		return
+	}
+
+	result := models.RolePolicy{
+		Role:  role,
+		Rules: make([]*models.PolicyRule, 0, len(rules)),
+	}
+	for _, rule := range rules {
+		result.Rules = append(result.Rules, &models.PolicyRule{
+			Resource: rule.Resource,
+			Action:   rule.Action,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
@@ -1,15 +1,23 @@
 package v1
 
 import (
-	"context"
+	"errors"
 	"net/http"
+	"sort"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ibrat-muslim/blog_app_api_gateway/api/models"
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
 	pbp "github.com/ibrat-muslim/blog_app_api_gateway/genproto/post_service"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+)
+
+// postsBatchSize is the page size used when the gateway has to read
+// all posts from the post service to filter them itself, and
+// postsMaxBatches bounds how many pages it reads for a single request.
+const (
+	postsBatchSize  = 100
+	postsMaxBatches = 50
 )
 
 // @Security ApiKeyAuth
@@ -29,17 +37,20 @@ func (h *handlerV1) CreatePost(ctx *gin.Context) {
 
 	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
 	payload, err := h.GetAuthPayload(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
-	resp, err := h.grpcClient.PostService().Create(context.Background(), &pbp.Post{
+	c, cancel := h.serviceContext(ctx, config.PostService)
+	defer cancel()
+
+	resp, err := h.grpcClient.PostService().Create(c, &pbp.Post{
 		Title:       req.Title,
 		Description: req.Description,
 		ImageUrl:    req.ImageUrl,
@@ -47,14 +58,19 @@ func (h *handlerV1) CreatePost(ctx *gin.Context) {
 		CategoryId:  req.CategoryID,
 	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to create post")
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, parsePostToModel(resp))
+	post, err := h.parsePostWithLikeInfo(resp)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, post)
 }
 
-/*
 // @Router /posts/{id} [get]
 // @Summary Get a post by id
 // @Description Get a post by id
@@ -70,86 +86,64 @@ func (h *handlerV1) GetPost(ctx *gin.Context) {
 
 	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	resp, err := h.storage.Post().Get(id)
+	c, cancel := h.serviceContext(ctx, config.PostService)
+	defer cancel()
+
+	resp, err := h.grpcClient.PostService().Get(c, &pbp.GetPostRequest{Id: id})
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to get post")
 		return
 	}
 
-	post := parsePostToModel(resp)
-
-	likeInfo, err := h.storage.Like().GetLikesDislikesCount(post.ID)
+	post, err := h.parsePostWithLikeInfo(resp)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
-	post.LikeInfo = &models.PostLikeInfo{
-		LikesCount:    likeInfo.LikesCount,
-		DislikesCount: likeInfo.DislikesCount,
-	}
-
 	ctx.JSON(http.StatusOK, post)
 }
 
 func validateGetPostsParams(ctx *gin.Context) (*models.GetPostsParams, error) {
 	var (
-		limit      int64 = 10
-		page       int64 = 1
-		err        error
 		userID     int64
 		categoryID int64
-		sortByDate string = "desc"
+		sortByDate string
 	)
 
-	if ctx.Query("limit") != "" {
-		limit, err = strconv.ParseInt(ctx.Query("limit"), 10, 64)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	if ctx.Query("page") != "" {
-		page, err = strconv.ParseInt(ctx.Query("page"), 10, 64)
-		if err != nil {
-			return nil, err
-		}
+	limit, page, err := validatePagination(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	if ctx.Query("user_id") != "" {
 		userID, err = strconv.ParseInt(ctx.Query("user_id"), 10, 64)
-		if err != nil {
-			return nil, err
+		if err != nil || userID <= 0 {
+			return nil, ErrInvalidUserID
 		}
 	}
 
 	if ctx.Query("category_id") != "" {
 		categoryID, err = strconv.ParseInt(ctx.Query("category_id"), 10, 64)
-		if err != nil {
-			return nil, err
+		if err != nil || categoryID <= 0 {
+			return nil, ErrInvalidCategoryID
 		}
 	}
 
-	if ctx.Query("sort_by_date") != "" &&
-		(ctx.Query("sort_by_date") == "asc" || ctx.Query("sort_by_date") == "desc") {
+	if ctx.Query("sort_by_date") != "" {
 		sortByDate = ctx.Query("sort_by_date")
+		if sortByDate != "asc" && sortByDate != "desc" {
+			return nil, ErrInvalidSortByDate
+		}
 	}
 
 	return &models.GetPostsParams{
-		Limit:      int32(limit),
-		Page:       int32(page),
+		Limit:      limit,
+		Page:       page,
 		Search:     ctx.Query("search"),
 		UserID:     userID,
 		CategoryID: categoryID,
@@ -170,62 +164,128 @@ func validateGetPostsParams(ctx *gin.Context) (*models.GetPostsParams, error) {
 func (h *handlerV1) GetPosts(ctx *gin.Context) {
 	request, err := validateGetPostsParams(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	result, err := h.storage.Post().GetAll(&repo.GetPostsParams{
-		Limit:      request.Limit,
-		Page:       request.Page,
-		Search:     request.Search,
-		UserID:     request.UserID,
-		CategoryID: request.CategoryID,
-		SortByDate: request.SortByDate,
-	})
+	var result *pbp.GetAllPostsResponse
+	if request.UserID != 0 || request.CategoryID != 0 || request.SortByDate != "" {
+		result, err = h.getFilteredPosts(ctx, request)
+		if errors.Is(err, ErrTooManyPosts) {
+			h.errorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+	} else {
+		c, cancel := h.serviceContext(ctx, config.PostService)
+		defer cancel()
+
+		result, err = h.grpcClient.PostService().GetAll(c, &pbp.GetAllPostsRequest{
+			Limit:  request.Limit,
+			Page:   request.Page,
+			Search: request.Search,
+		})
+	}
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to get all posts")
 		return
 	}
 
-	response, err := getPostsResponse(h, result)
+	response, err := h.getPostsResponse(result)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, response)
 }
 
-func getPostsResponse(h *handlerV1, data *repo.GetPostsResult) (*models.GetPostsResponse, error) {
+// getFilteredPosts applies the filters the post service does not support yet
+// (user_id, category_id and sort_by_date) in the gateway. It reads every post
+// matching the search, filters and sorts them, and then paginates the result.
+// All reads share one post service deadline, and searches matching more than
+// postsMaxBatches pages are rejected with ErrTooManyPosts.
+func (h *handlerV1) getFilteredPosts(ctx *gin.Context, params *models.GetPostsParams) (*pbp.GetAllPostsResponse, error) {
+	posts := make([]*pbp.Post, 0)
+
+	c, cancel := h.serviceContext(ctx, config.PostService)
+	defer cancel()
+
+	for page := int32(1); ; page++ {
+		if page > postsMaxBatches {
+			return nil, ErrTooManyPosts
+		}
+
+		result, err := h.grpcClient.PostService().GetAll(c, &pbp.GetAllPostsRequest{
+			Limit:  postsBatchSize,
+			Page:   page,
+			Search: params.Search,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, post := range result.Posts {
+			if params.UserID != 0 && post.UserId != params.UserID {
+				continue
+			}
+			if params.CategoryID != 0 && post.CategoryId != params.CategoryID {
+				continue
+			}
+			posts = append(posts, post)
+		}
+
+		if len(result.Posts) < postsBatchSize || page*postsBatchSize >= result.Count {
+			break
+		}
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		if params.SortByDate == "asc" {
+			return posts[i].CreatedAt < posts[j].CreatedAt
+		}
+		return posts[i].CreatedAt > posts[j].CreatedAt
+	})
+
+	response := pbp.GetAllPostsResponse{
+		Posts: make([]*pbp.Post, 0),
+		Count: int32(len(posts)),
+	}
+
+	offset := (int64(params.Page) - 1) * int64(params.Limit)
+	if offset >= int64(len(posts)) {
+		return &response, nil
+	}
+
+	end := offset + int64(params.Limit)
+	if end > int64(len(posts)) {
+		end = int64(len(posts))
+	}
+	response.Posts = posts[offset:end]
+
+	return &response, nil
+}
+
+func (h *handlerV1) getPostsResponse(data *pbp.GetAllPostsResponse) (*models.GetPostsResponse, error) {
 	response := models.GetPostsResponse{
 		Posts: make([]*models.Post, 0),
 		Count: data.Count,
 	}
 
 	for _, post := range data.Posts {
-		p := parsePostToModel(post)
-
-		likeInfo, err := h.storage.Like().GetLikesDislikesCount(p.ID)
+		p, err := h.parsePostWithLikeInfo(post)
 		if err != nil {
 			return nil, err
 		}
-
-		p.LikeInfo = &models.PostLikeInfo{
-			LikesCount:    likeInfo.LikesCount,
-			DislikesCount: likeInfo.DislikesCount,
-		}
-
 		response.Posts = append(response.Posts, &p)
 	}
 
 	return &response, nil
 }
-*/
 
 // @Security ApiKeyAuth
 // @Router /posts/{id} [put]
 // @Summary Update a post
-// @Description Update a post
+// @Description Update a post. Only the author or a superadmin is allowed.
 // @Tags post
 // @Accept json
 // @Produce json
@@ -233,6 +293,7 @@ func getPostsResponse(h *handlerV1, data *repo.GetPostsResult) (*models.GetPosts
 // @Param post body models.CreatePostRequest true "Post"
 // @Success 201 {object} models.Post
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) UpdatePost(ctx *gin.Context) {
@@ -240,77 +301,86 @@ func (h *handlerV1) UpdatePost(ctx *gin.Context) {
 
 	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
 	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
 	payload, err := h.GetAuthPayload(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
-	resp, err := h.grpcClient.PostService().Update(context.Background(), &pbp.Post{
+	c, cancel := h.serviceContext(ctx, config.PostService)
+	defer cancel()
+
+	resp, err := h.grpcClient.PostService().Update(c, &pbp.Post{
 		Id:          id,
 		Title:       req.Title,
 		Description: req.Description,
 		ImageUrl:    req.ImageUrl,
-		UserId:      payload.UserID,
+		UserId:      h.resourceOwner(ctx, payload.UserID),
 		CategoryId:  req.CategoryID,
 	})
 	if err != nil {
-		if s, _ := status.FromError(err); s.Code() == codes.NotFound {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to update post")
+		return
+	}
+
+	post, err := h.parsePostWithLikeInfo(resp)
+	if err != nil {
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, parsePostToModel(resp))
+	ctx.JSON(http.StatusOK, post)
 }
 
-/*
 // @Security ApiKeyAuth
 // @Router /posts/{id} [delete]
 // @Summary Delete a post
-// @Description Delete a post
+// @Description Delete a post. Only the author or a superadmin is allowed.
 // @Tags post
 // @Accept json
 // @Produce json
 // @Param id path int true "ID"
 // @Success 200 {object} models.OKResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) DeletePost(ctx *gin.Context) {
 	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	err = h.storage.Post().Delete(id)
+	c, cancel := h.serviceContext(ctx, config.PostService)
+	defer cancel()
+
+	_, err = h.grpcClient.PostService().Delete(c, &pbp.GetPostRequest{Id: id})
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to delete post")
 		return
 	}
 
+	// The post is gone at this point, so a failure only leaves stale likes
+	// behind and is not reported to the client.
+	if err := h.storage.Like().DeleteByPost(id); err != nil {
+		h.log(ctx).WithError(err).WithField("post_id", id).Error("failed to delete likes of post")
+	}
+
 	ctx.JSON(http.StatusOK, models.OKResponse{
 		Message: "successfully deleted",
 	})
 }
-*/
 
 func parsePostToModel(post *pbp.Post) models.Post {
 	return models.Post{
@@ -321,6 +391,23 @@ func parsePostToModel(post *pbp.Post) models.Post {
 		UserID:      post.UserId,
 		CategoryID:  post.CategoryId,
 		CreatedAt:   post.CreatedAt,
+		UpdatedAt:   post.UpdatedAt,
 		ViewsCount:  post.ViewsCount,
 	}
 }
+
+func (h *handlerV1) parsePostWithLikeInfo(post *pbp.Post) (models.Post, error) {
+	p := parsePostToModel(post)
+
+	likeInfo, err := h.storage.Like().GetLikesDislikesCount(p.ID)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	p.LikeInfo = models.PostLikeInfo{
+		LikesCount:    likeInfo.LikesCount,
+		DislikesCount: likeInfo.DislikesCount,
+	}
+
+	return p, nil
+}
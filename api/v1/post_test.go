@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ibrat-muslim/blog_app_api_gateway/api/models"
+)
+
+func TestValidateGetPostsParams(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    models.GetPostsParams
+		wantErr error
+	}{
+		{
+			query: "",
+			want:  models.GetPostsParams{Limit: 10, Page: 1},
+		},
+		{
+			query: "limit=5&page=3&search=go&user_id=7&category_id=2&sort_by_date=asc",
+			want:  models.GetPostsParams{Limit: 5, Page: 3, Search: "go", UserID: 7, CategoryID: 2, SortByDate: "asc"},
+		},
+		{query: "limit=0", wantErr: ErrInvalidLimit},
+		{query: "limit=3000000000", wantErr: ErrInvalidLimit},
+		{query: "page=-1", wantErr: ErrInvalidPage},
+		{query: "user_id=abc", wantErr: ErrInvalidUserID},
+		{query: "user_id=0", wantErr: ErrInvalidUserID},
+		{query: "user_id=99999999999999999999", wantErr: ErrInvalidUserID},
+		{query: "category_id=1.5", wantErr: ErrInvalidCategoryID},
+		{query: "category_id=-2", wantErr: ErrInvalidCategoryID},
+		{query: "sort_by_date=newest", wantErr: ErrInvalidSortByDate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/posts?"+tt.query, nil)
+
+			got, err := validateGetPostsParams(ctx)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if _, ok := errorCodes[tt.wantErr]; !ok {
+					t.Fatalf("%v has no error code", tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if *got != tt.want {
+				t.Fatalf("params = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
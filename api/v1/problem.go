@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/ibrat-muslim/blog_app_api_gateway/api/models"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/requestid"
+)
+
+const problemJSONContentType = "application/problem+json"
+
+func init() {
+	// Report validation errors with the json names of the fields, since
+	// those are what clients send.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// wantsProblemJSON reports whether the client prefers RFC 7807 documents
+// over the legacy error shape. The legacy shape stays the default.
+func wantsProblemJSON(ctx *gin.Context) bool {
+	return ctx.NegotiateFormat(gin.MIMEJSON, problemJSONContentType) == problemJSONContentType
+}
+
+func (h *handlerV1) abortWithProblem(ctx *gin.Context, httpStatus int, code, message string, fieldErrors []models.FieldError) {
+	problemType := "about:blank"
+	if h.cfg.ProblemTypeBaseURL != "" {
+		problemType = strings.TrimSuffix(h.cfg.ProblemTypeBaseURL, "/") + "/" + code
+	}
+
+	title := http.StatusText(httpStatus)
+	if title == "" {
+		title = statusMessage(httpStatus)
+	}
+
+	ctx.Header("Content-Type", problemJSONContentType)
+	ctx.AbortWithStatusJSON(httpStatus, models.ProblemDetails{
+		Type:      problemType,
+		Title:     title,
+		Status:    httpStatus,
+		Detail:    message,
+		Instance:  ctx.Request.URL.Path,
+		Code:      code,
+		RequestID: requestid.FromContext(ctx.Request.Context()),
+		Errors:    fieldErrors,
+	})
+}
+
+// bindingFieldErrors extracts per-field errors from the errors returned by
+// gin's binding, so clients can point them at the offending inputs.
+func bindingFieldErrors(err error) []models.FieldError {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		result := make([]models.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			result = append(result, models.FieldError{
+				Field:   fe.Field(),
+				Message: validationMessage(fe),
+			})
+		}
+		return result
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return []models.FieldError{{
+			Field:   typeErr.Field,
+			Message: fmt.Sprintf("must be of type %s", typeErr.Type),
+		}}
+	}
+
+	return nil
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s characters long", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters long", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fe.Param())
+	}
+	return fmt.Sprintf("failed on the %q validation", fe.Tag())
+}
@@ -1,15 +1,13 @@
 package v1
 
 import (
-	"context"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ibrat-muslim/blog_app_api_gateway/api/models"
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
 	pbu "github.com/ibrat-muslim/blog_app_api_gateway/genproto/user_service"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 // @Security ApiKeyAuth
@@ -29,11 +27,14 @@ func (h *handlerV1) CreateUser(ctx *gin.Context) {
 
 	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	user, err := h.grpcClient.UserService().Create(context.Background(), &pbu.User{
+	c, cancel := h.serviceContext(ctx, config.UserService)
+	defer cancel()
+
+	user, err := h.grpcClient.UserService().Create(c, &pbu.User{
 		FirstName:       req.FirstName,
 		LastName:        req.LastName,
 		PhoneNumber:     req.PhoneNumber,
@@ -45,8 +46,7 @@ func (h *handlerV1) CreateUser(ctx *gin.Context) {
 		Type:            req.Type,
 	})
 	if err != nil {
-		h.logger.WithError(err).Error("failed to create user")
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to create user")
 		return
 	}
 
@@ -68,18 +68,16 @@ func (h *handlerV1) GetUser(ctx *gin.Context) {
 
 	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	resp, err := h.grpcClient.UserService().Get(context.Background(), &pbu.GetUserRequest{Id: id})
+	c, cancel := h.serviceContext(ctx, config.UserService)
+	defer cancel()
+
+	resp, err := h.grpcClient.UserService().Get(c, &pbu.GetUserRequest{Id: id})
 	if err != nil {
-		h.logger.WithError(err).Error("failed to get user")
-		if s, _ := status.FromError(err); s.Code() == codes.NotFound {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to get user")
 		return
 	}
 
@@ -99,14 +97,12 @@ func (h *handlerV1) GetUser(ctx *gin.Context) {
 func (h *handlerV1) GetUserByEmail(ctx *gin.Context) {
 	email := ctx.Param("email")
 
-	resp, err := h.grpcClient.UserService().GetByEmail(context.Background(), &pbu.EmailRequest{Email: email})
+	c, cancel := h.serviceContext(ctx, config.UserService)
+	defer cancel()
+
+	resp, err := h.grpcClient.UserService().GetByEmail(c, &pbu.EmailRequest{Email: email})
 	if err != nil {
-		h.logger.WithError(err).Error("failed to get user by email")
-		if s, _ := status.FromError(err); s.Code() == codes.NotFound {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to get user by email")
 		return
 	}
 
@@ -125,20 +121,18 @@ func (h *handlerV1) GetUserByEmail(ctx *gin.Context) {
 func (h *handlerV1) GetUserProfile(ctx *gin.Context) {
 	payload, err := h.GetAuthPayload(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.errorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
-	resp, err := h.grpcClient.UserService().Get(context.Background(), &pbu.GetUserRequest{
+	c, cancel := h.serviceContext(ctx, config.UserService)
+	defer cancel()
+
+	resp, err := h.grpcClient.UserService().Get(c, &pbu.GetUserRequest{
 		Id: payload.UserID,
 	})
 	if err != nil {
-		h.logger.WithError(err).Error("failed to get user profile")
-		if s, _ := status.FromError(err); s.Code() == codes.NotFound {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to get user profile")
 		return
 	}
 
@@ -158,18 +152,20 @@ func (h *handlerV1) GetUserProfile(ctx *gin.Context) {
 func (h *handlerV1) GetUsers(ctx *gin.Context) {
 	request, err := validateGetAllParamsRequest(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	result, err := h.grpcClient.UserService().GetAll(context.Background(), &pbu.GetAllUsersRequest{
+	c, cancel := h.serviceContext(ctx, config.UserService)
+	defer cancel()
+
+	result, err := h.grpcClient.UserService().GetAll(c, &pbu.GetAllUsersRequest{
 		Limit:  request.Limit,
 		Page:   request.Page,
 		Search: request.Search,
 	})
 	if err != nil {
-		h.logger.WithError(err).Error("failed to get all users")
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to get all users")
 		return
 	}
 
@@ -193,7 +189,7 @@ func getUsersResponse(data *pbu.GetAllUsersResponse) *models.GetUsersResponse {
 // @Security ApiKeyAuth
 // @Router /users/{id} [put]
 // @Summary Update user
-// @Description Update user
+// @Description Update user. Only the user themselves or a superadmin is allowed.
 // @Tags user
 // @Accept json
 // @Produce json
@@ -201,6 +197,7 @@ func getUsersResponse(data *pbu.GetAllUsersResponse) *models.GetUsersResponse {
 // @Param user body models.UpdateUserRequest true "User"
 // @Success 200 {object} models.User
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) UpdateUser(ctx *gin.Context) {
@@ -208,17 +205,20 @@ func (h *handlerV1) UpdateUser(ctx *gin.Context) {
 
 	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
 	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	user, err := h.grpcClient.UserService().Update(context.Background(), &pbu.User{
+	c, cancel := h.serviceContext(ctx, config.UserService)
+	defer cancel()
+
+	user, err := h.grpcClient.UserService().Update(c, &pbu.User{
 		Id:              id,
 		FirstName:       req.FirstName,
 		LastName:        req.LastName,
@@ -228,12 +228,7 @@ func (h *handlerV1) UpdateUser(ctx *gin.Context) {
 		ProfileImageUrl: req.ProfileImageUrl,
 	})
 	if err != nil {
-		h.logger.WithError(err).Error("failed to update user")
-		if s, _ := status.FromError(err); s.Code() == codes.NotFound {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to update user")
 		return
 	}
 
@@ -243,30 +238,29 @@ func (h *handlerV1) UpdateUser(ctx *gin.Context) {
 // @Security ApiKeyAuth
 // @Router /users/{id} [delete]
 // @Summary Delete user
-// @Description Delete user
+// @Description Delete user. Only the user themselves or a superadmin is allowed.
 // @Tags user
 // @Accept json
 // @Produce json
 // @Param id path int true "ID"
 // @Success 200 {object} models.OKResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) DeleteUser(ctx *gin.Context) {
 	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		h.errorResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	_, err = h.grpcClient.UserService().Delete(context.Background(), &pbu.GetUserRequest{Id: id})
+	c, cancel := h.serviceContext(ctx, config.UserService)
+	defer cancel()
+
+	_, err = h.grpcClient.UserService().Delete(c, &pbu.GetUserRequest{Id: id})
 	if err != nil {
-		h.logger.WithError(err).Error("failed to delete user")
-		if s, _ := status.FromError(err); s.Code() == codes.NotFound {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		h.grpcErrorResponse(ctx, err, "failed to delete user")
 		return
 	}
 
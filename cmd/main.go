@@ -1,32 +1,213 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
 	_ "github.com/lib/pq"
 
 	"github.com/ibrat-muslim/blog_app_api_gateway/api"
 	"github.com/ibrat-muslim/blog_app_api_gateway/config"
 	grpcPkg "github.com/ibrat-muslim/blog_app_api_gateway/pkg/grpc_client"
 	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/logger"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/ratelimit"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/rbac"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/tlsutil"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/token"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/tracing"
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage"
 )
 
 func main() {
 	cfg := config.Load(".")
 
-	log := logger.New()
+	log := logger.New(cfg)
+
+	exporter, err := tracing.NewExporter(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("failed to create trace exporter: %v", err)
+	}
+
+	tracerProvider, err := tracing.NewProvider(cfg, exporter)
+	if err != nil {
+		log.Fatalf("failed to create tracer provider: %v", err)
+	}
 
 	grpcClient, err := grpcPkg.New(cfg)
 	if err != nil {
 		log.Fatalf("failed to get grpc connection: %v", err)
 	}
 
-	apiServer := api.New(&api.RouterOptions{
-		Cfg:        &cfg,
-		GrpcClient: grpcClient,
-		Logger:     log,
-	})
+	rateLimits, err := ratelimit.Parse(cfg.RateLimitPolicies)
+	if err != nil {
+		log.Fatalf("failed to parse rate limit policies: %v", err)
+	}
+
+	ipRateLimits, err := ratelimit.Parse(cfg.RateLimitIPPolicies)
+	if err != nil {
+		log.Fatalf("failed to parse ip rate limit policies: %v", err)
+	}
 
-	err = apiServer.Run(cfg.HttpPort)
+	verifier, err := token.NewVerifier(cfg)
 	if err != nil {
-		log.Fatalf("failed to run server: %v", err)
+		log.Fatalf("failed to create token verifier: %v", err)
+	}
+
+	// Refreshed access tokens are signed by the gateway, so they are only
+	// issued when no request ever takes them to the user service: tokens
+	// must be verified locally and permissions decided by a local policy.
+	var tokenMaker *token.Maker
+	if cfg.AuthMode == token.ModeLocal && cfg.AuthSigningKey != "" && cfg.RBACPolicyFile != "" {
+		tokenMaker, err = token.NewHMACMaker(cfg.AuthSigningKey, cfg.AccessTokenTTL)
+		if err != nil {
+			log.Fatalf("failed to create token maker: %v", err)
+		}
+
+		if err := tokenMaker.VerifiedBy(verifier); err != nil {
+			log.Fatalf("issued access tokens fail local verification: %v", err)
+		}
+	} else {
+		log.Info("refresh tokens are disabled, they need AUTH_MODE=local with AUTH_SIGNING_KEY and RBAC_POLICY_FILE")
+	}
+
+	var policy *rbac.Engine
+	if cfg.RBACPolicyFile != "" {
+		policy, err = rbac.Load(cfg.RBACPolicyFile)
+		if err != nil {
+			log.Fatalf("failed to load rbac policy: %v", err)
+		}
+
+		err = policy.Watch(func(err error) {
+			if err != nil {
+				log.Errorf("failed to reload rbac policy, keeping the previous one: %v", err)
+				return
+			}
+			log.Info("rbac policy reloaded")
+		})
+		if err != nil {
+			log.Fatalf("failed to watch rbac policy: %v", err)
+		}
+	}
+
+	apiServer, err := api.New(&api.RouterOptions{
+		Cfg:          &cfg,
+		GrpcClient:   grpcClient,
+		Storage:      storage.NewStorageInMemory(),
+		Logger:       log,
+		RateLimits:   rateLimits,
+		IPRateLimits: ipRateLimits,
+		Verifier:     verifier,
+		Policy:       policy,
+		TokenMaker:   tokenMaker,
+	})
+	if err != nil {
+		log.Fatalf("failed to create router: %v", err)
+	}
+
+	srv := &http.Server{
+		Addr:         cfg.HttpPort,
+		Handler:      apiServer,
+		ReadTimeout:  cfg.HttpReadTimeout,
+		WriteTimeout: cfg.HttpWriteTimeout,
+		IdleTimeout:  cfg.HttpIdleTimeout,
 	}
+
+	var certReloader *tlsutil.Reloader
+	if cfg.HttpTLSCertFile != "" || cfg.HttpTLSKeyFile != "" {
+		certReloader, err = tlsutil.NewReloader(cfg.HttpTLSCertFile, cfg.HttpTLSKeyFile, "")
+		if err != nil {
+			log.Fatalf("failed to load server certificate: %v", err)
+		}
+		srv.TLSConfig = certReloader.ServerConfig()
+	}
+
+	go func() {
+		var err error
+		if certReloader != nil {
+			log.Infof("server is running on %s with TLS", cfg.HttpPort)
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			log.Infof("server is running on %s", cfg.HttpPort)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("failed to run server: %v", err)
+		}
+	}()
+
+	var redirectSrv *http.Server
+	if certReloader != nil && cfg.HttpRedirectPort != "" {
+		redirectSrv = &http.Server{
+			Addr:         cfg.HttpRedirectPort,
+			Handler:      api.NewRedirectHandler(cfg.HttpPort),
+			ReadTimeout:  cfg.HttpReadTimeout,
+			WriteTimeout: cfg.HttpWriteTimeout,
+			IdleTimeout:  cfg.HttpIdleTimeout,
+		}
+
+		go func() {
+			log.Infof("redirecting HTTP on %s to HTTPS", cfg.HttpRedirectPort)
+			if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("failed to run redirect server: %v", err)
+			}
+		}()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	// SIGHUP reloads the certificate when serving TLS. Otherwise it is left
+	// alone and terminates the process as usual.
+	hup := make(chan os.Signal, 1)
+	if certReloader != nil {
+		signal.Notify(hup, syscall.SIGHUP)
+	}
+
+	var sig os.Signal
+	for sig == nil {
+		select {
+		case <-hup:
+			if err := certReloader.Reload(); err != nil {
+				log.Errorf("failed to reload server certificate, keeping the previous one: %v", err)
+			} else {
+				log.Info("server certificate reloaded")
+			}
+		case sig = <-quit:
+		}
+	}
+
+	log.Infof("received %s, shutting down server", sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if redirectSrv != nil {
+		if err := redirectSrv.Shutdown(ctx); err != nil {
+			log.Errorf("failed to shutdown redirect server gracefully: %v", err)
+		}
+	}
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Errorf("failed to shutdown server gracefully: %v", err)
+	}
+
+	if policy != nil {
+		if err := policy.Close(); err != nil {
+			log.Errorf("failed to stop watching rbac policy: %v", err)
+		}
+	}
+
+	if err := grpcClient.Close(); err != nil {
+		log.Errorf("failed to close grpc connections: %v", err)
+	}
+
+	if err := tracerProvider.Shutdown(ctx); err != nil {
+		log.Errorf("failed to flush traces: %v", err)
+	}
+
+	log.Info("server stopped")
 }
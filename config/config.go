@@ -2,17 +2,112 @@ package config
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
 )
 
+// TLS configures the connection to a backend service.
+type TLS struct {
+	// Insecure disables TLS altogether. It must be set explicitly.
+	Insecure   bool
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+}
+
+// Service describes how the gateway reaches a backend service. Addresses,
+// when set, takes precedence over Host and GrpcPort.
+type Service struct {
+	Name      string
+	Host      string
+	GrpcPort  string
+	Addresses string
+	LBPolicy  string
+	Timeout   time.Duration
+	// Required services must be reachable for the gateway to be ready.
+	Required bool
+	TLS      TLS
+	Retry    Retry
+}
+
+// Retry configures retries of idempotent calls to a backend service.
+type Retry struct {
+	Methods     []string
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Names of the backend services.
+const (
+	UserService         = "user_service"
+	AuthService         = "auth_service"
+	PostService         = "post_service"
+	CategoryService     = "category_service"
+	NotificationService = "notification_service"
+)
+
+// backends declares every service the gateway talks to. Settings are read
+// from the keys starting with the upper-cased name, e.g. USER_SERVICE_HOST.
+// Whatever is not set is taken from the fallback service, so services that
+// are deployed together share one configuration.
+var backends = []struct {
+	name     string
+	fallback string
+	required bool
+}{
+	{name: UserService, required: true},
+	{name: AuthService, fallback: UserService},
+	{name: PostService, required: true},
+	{name: CategoryService, fallback: PostService},
+	{name: NotificationService},
+}
+
 type Config struct {
-	HttpPort            string
-	UserServiceHost     string
-	UserServiceGrpcPort string
-	PostServiceHost     string
-	PostServiceGrpcPort string
+	HttpPort                string
+	HttpReadTimeout         time.Duration
+	HttpWriteTimeout        time.Duration
+	HttpIdleTimeout         time.Duration
+	HttpTLSCertFile         string
+	HttpTLSKeyFile          string
+	HttpRedirectPort        string
+	TrustedProxies          []string
+	HSTSMaxAge              time.Duration
+	ShutdownTimeout         time.Duration
+	HealthCheckTimeout      time.Duration
+	ProblemTypeBaseURL      string
+	LogLevel                string
+	LogFormat               string
+	LogPrettyPrint          bool
+	RateLimitPolicies       string
+	RateLimitIPPolicies     string
+	LockoutMaxAttempts      int64
+	LockoutMaxAttemptsPerIP int64
+	LockoutBackoff          time.Duration
+	LockoutDuration         time.Duration
+	LockoutWindow           time.Duration
+	AuthCacheTTL            time.Duration
+	AuthCacheSize           int
+	AuthMode                string
+	AuthSigningKey          string
+	AuthJWKSFile            string
+	RBACPolicyFile          string
+	AccessTokenTTL          time.Duration
+	RefreshTokenTTL         time.Duration
+	ServiceName             string
+	TracingExporter         string
+	TracingSampleRatio      float64
+	OTLPEndpoint            string
+	OTLPInsecure            bool
+	Services                []Service
+	GrpcBreakerThreshold    int
+	GrpcBreakerOpenTimeout  time.Duration
+	GrpcEjectionThreshold   int
+	GrpcEjectionDuration    time.Duration
 }
 
 func Load(path string) Config {
@@ -24,13 +119,196 @@ func Load(path string) Config {
 	conf := viper.New()
 	conf.AutomaticEnv()
 
+	conf.SetDefault("HTTP_READ_TIMEOUT", 15*time.Second)
+	conf.SetDefault("HTTP_WRITE_TIMEOUT", 30*time.Second)
+	conf.SetDefault("HTTP_IDLE_TIMEOUT", 60*time.Second)
+	conf.SetDefault("HSTS_MAX_AGE", 365*24*time.Hour)
+	conf.SetDefault("SHUTDOWN_TIMEOUT", 20*time.Second)
+	conf.SetDefault("HEALTH_CHECK_TIMEOUT", 2*time.Second)
+	conf.SetDefault("LOG_LEVEL", "info")
+	conf.SetDefault("LOG_FORMAT", "json")
+	conf.SetDefault("LOG_PRETTY_PRINT", true)
+	conf.SetDefault("RATE_LIMIT_POLICIES", "*=120/1m")
+	conf.SetDefault("RATE_LIMIT_IP_POLICIES", "POST /v1/auth/register=5/1m,POST /v1/auth/login=10/1m,POST /v1/auth/verify=10/1m,POST /v1/auth/forgot-password=3/1m,POST /v1/auth/verify-forgot-password=10/1m,POST /v1/auth/refresh=10/1m,POST /v1/auth/logout=10/1m,*=600/1m")
+	conf.SetDefault("LOCKOUT_MAX_ATTEMPTS", 5)
+	conf.SetDefault("LOCKOUT_MAX_ATTEMPTS_PER_IP", 20)
+	conf.SetDefault("LOCKOUT_BACKOFF", time.Second)
+	conf.SetDefault("LOCKOUT_DURATION", 15*time.Minute)
+	conf.SetDefault("LOCKOUT_WINDOW", 15*time.Minute)
+	conf.SetDefault("AUTH_CACHE_TTL", 30*time.Second)
+	conf.SetDefault("AUTH_CACHE_SIZE", 10000)
+	conf.SetDefault("AUTH_MODE", "remote")
+	conf.SetDefault("ACCESS_TOKEN_TTL", 15*time.Minute)
+	conf.SetDefault("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	conf.SetDefault("SERVICE_NAME", "api_gateway")
+	conf.SetDefault("TRACING_EXPORTER", "none")
+	conf.SetDefault("TRACING_SAMPLE_RATIO", 1.0)
+	conf.SetDefault("OTLP_ENDPOINT", "localhost:4317")
+	conf.SetDefault("USER_SERVICE_LB_POLICY", "round_robin")
+	conf.SetDefault("POST_SERVICE_LB_POLICY", "round_robin")
+	conf.SetDefault("NOTIFICATION_SERVICE_LB_POLICY", "round_robin")
+	conf.SetDefault("USER_SERVICE_TIMEOUT", 5*time.Second)
+	conf.SetDefault("POST_SERVICE_TIMEOUT", 5*time.Second)
+	conf.SetDefault("NOTIFICATION_SERVICE_TIMEOUT", 10*time.Second)
+	conf.SetDefault("GRPC_RETRY_METHODS", "Get,GetAll,GetByEmail,VerifyToken")
+	conf.SetDefault("GRPC_RETRY_MAX_ATTEMPTS", 3)
+	conf.SetDefault("GRPC_RETRY_BACKOFF", 100*time.Millisecond)
+	conf.SetDefault("GRPC_RETRY_MAX_BACKOFF", time.Second)
+	conf.SetDefault("GRPC_BREAKER_THRESHOLD", 5)
+	conf.SetDefault("GRPC_BREAKER_OPEN_TIMEOUT", 30*time.Second)
+	conf.SetDefault("GRPC_EJECTION_THRESHOLD", 5)
+	conf.SetDefault("GRPC_EJECTION_DURATION", 30*time.Second)
+
 	cfg := Config{
-		HttpPort:            conf.GetString("HTTP_PORT"),
-		UserServiceHost:     conf.GetString("USER_SERVICE_HOST"),
-		UserServiceGrpcPort: conf.GetString("USER_SERVICE_GRPC_PORT"),
-		PostServiceHost:     conf.GetString("POST_SERVICE_HOST"),
-		PostServiceGrpcPort: conf.GetString("POST_SERVICE_GRPC_PORT"),
+		HttpPort:                conf.GetString("HTTP_PORT"),
+		HttpReadTimeout:         conf.GetDuration("HTTP_READ_TIMEOUT"),
+		HttpWriteTimeout:        conf.GetDuration("HTTP_WRITE_TIMEOUT"),
+		HttpIdleTimeout:         conf.GetDuration("HTTP_IDLE_TIMEOUT"),
+		HttpTLSCertFile:         conf.GetString("HTTP_TLS_CERT_FILE"),
+		HttpTLSKeyFile:          conf.GetString("HTTP_TLS_KEY_FILE"),
+		HttpRedirectPort:        conf.GetString("HTTP_REDIRECT_PORT"),
+		TrustedProxies:          splitList(conf.GetString("TRUSTED_PROXIES")),
+		HSTSMaxAge:              conf.GetDuration("HSTS_MAX_AGE"),
+		ShutdownTimeout:         conf.GetDuration("SHUTDOWN_TIMEOUT"),
+		HealthCheckTimeout:      conf.GetDuration("HEALTH_CHECK_TIMEOUT"),
+		ProblemTypeBaseURL:      conf.GetString("PROBLEM_TYPE_BASE_URL"),
+		LogLevel:                conf.GetString("LOG_LEVEL"),
+		LogFormat:               conf.GetString("LOG_FORMAT"),
+		LogPrettyPrint:          conf.GetBool("LOG_PRETTY_PRINT"),
+		RateLimitPolicies:       conf.GetString("RATE_LIMIT_POLICIES"),
+		RateLimitIPPolicies:     conf.GetString("RATE_LIMIT_IP_POLICIES"),
+		LockoutMaxAttempts:      conf.GetInt64("LOCKOUT_MAX_ATTEMPTS"),
+		LockoutMaxAttemptsPerIP: conf.GetInt64("LOCKOUT_MAX_ATTEMPTS_PER_IP"),
+		LockoutBackoff:          conf.GetDuration("LOCKOUT_BACKOFF"),
+		LockoutDuration:         conf.GetDuration("LOCKOUT_DURATION"),
+		LockoutWindow:           conf.GetDuration("LOCKOUT_WINDOW"),
+		AuthCacheTTL:            conf.GetDuration("AUTH_CACHE_TTL"),
+		AuthCacheSize:           conf.GetInt("AUTH_CACHE_SIZE"),
+		AuthMode:                conf.GetString("AUTH_MODE"),
+		AuthSigningKey:          conf.GetString("AUTH_SIGNING_KEY"),
+		AuthJWKSFile:            conf.GetString("AUTH_JWKS_FILE"),
+		RBACPolicyFile:          conf.GetString("RBAC_POLICY_FILE"),
+		AccessTokenTTL:          conf.GetDuration("ACCESS_TOKEN_TTL"),
+		RefreshTokenTTL:         conf.GetDuration("REFRESH_TOKEN_TTL"),
+		ServiceName:             conf.GetString("SERVICE_NAME"),
+		TracingExporter:         conf.GetString("TRACING_EXPORTER"),
+		TracingSampleRatio:      conf.GetFloat64("TRACING_SAMPLE_RATIO"),
+		OTLPEndpoint:            conf.GetString("OTLP_ENDPOINT"),
+		OTLPInsecure:            conf.GetBool("OTLP_INSECURE"),
+		GrpcBreakerThreshold:    conf.GetInt("GRPC_BREAKER_THRESHOLD"),
+		GrpcBreakerOpenTimeout:  conf.GetDuration("GRPC_BREAKER_OPEN_TIMEOUT"),
+		GrpcEjectionThreshold:   conf.GetInt("GRPC_EJECTION_THRESHOLD"),
+		GrpcEjectionDuration:    conf.GetDuration("GRPC_EJECTION_DURATION"),
+	}
+
+	retry := Retry{
+		Methods:     strings.Split(conf.GetString("GRPC_RETRY_METHODS"), ","),
+		MaxAttempts: conf.GetInt("GRPC_RETRY_MAX_ATTEMPTS"),
+		Backoff:     conf.GetDuration("GRPC_RETRY_BACKOFF"),
+		MaxBackoff:  conf.GetDuration("GRPC_RETRY_MAX_BACKOFF"),
+	}
+
+	loaded := make(map[string]Service)
+	for _, b := range backends {
+		base := Service{Required: b.required, Retry: retry}
+		if b.fallback != "" {
+			base = loaded[b.fallback]
+		}
+
+		svc := loadService(conf, b.name, strings.ToUpper(b.name), base)
+		loaded[b.name] = svc
+		cfg.Services = append(cfg.Services, svc)
 	}
 
 	return cfg
 }
+
+// Service returns the settings of the named backend.
+func (c Config) Service(name string) Service {
+	for _, svc := range c.Services {
+		if svc.Name == name {
+			return svc
+		}
+	}
+	return Service{Name: name}
+}
+
+// loadService reads the settings of a backend from the keys starting with
+// prefix. Anything that is not set is taken from base.
+func loadService(conf *viper.Viper, name, prefix string, base Service) Service {
+	s := base
+	s.Name = name
+
+	set := func(key string) bool {
+		return conf.IsSet(prefix + "_" + key)
+	}
+
+	if set("HOST") {
+		s.Host = conf.GetString(prefix + "_HOST")
+	}
+	if set("GRPC_PORT") {
+		s.GrpcPort = conf.GetString(prefix + "_GRPC_PORT")
+	}
+	if set("ADDRESSES") {
+		s.Addresses = conf.GetString(prefix + "_ADDRESSES")
+	}
+	if set("LB_POLICY") {
+		s.LBPolicy = conf.GetString(prefix + "_LB_POLICY")
+	}
+	if set("TIMEOUT") {
+		s.Timeout = conf.GetDuration(prefix + "_TIMEOUT")
+	}
+	if set("REQUIRED") {
+		s.Required = conf.GetBool(prefix + "_REQUIRED")
+	}
+	if set("RETRY_METHODS") {
+		s.Retry.Methods = strings.Split(conf.GetString(prefix+"_RETRY_METHODS"), ",")
+	}
+	if set("RETRY_MAX_ATTEMPTS") {
+		s.Retry.MaxAttempts = conf.GetInt(prefix + "_RETRY_MAX_ATTEMPTS")
+	}
+	if set("RETRY_BACKOFF") {
+		s.Retry.Backoff = conf.GetDuration(prefix + "_RETRY_BACKOFF")
+	}
+	if set("RETRY_MAX_BACKOFF") {
+		s.Retry.MaxBackoff = conf.GetDuration(prefix + "_RETRY_MAX_BACKOFF")
+	}
+
+	s.TLS = loadTLS(conf, prefix, base.TLS)
+
+	return s
+}
+
+// splitList splits a comma separated value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func loadTLS(conf *viper.Viper, prefix string, base TLS) TLS {
+	t := base
+
+	if conf.IsSet(prefix + "_INSECURE") {
+		t.Insecure = conf.GetBool(prefix + "_INSECURE")
+	}
+	if conf.IsSet(prefix + "_CA_FILE") {
+		t.CAFile = conf.GetString(prefix + "_CA_FILE")
+	}
+	if conf.IsSet(prefix + "_CERT_FILE") {
+		t.CertFile = conf.GetString(prefix + "_CERT_FILE")
+	}
+	if conf.IsSet(prefix + "_KEY_FILE") {
+		t.KeyFile = conf.GetString(prefix + "_KEY_FILE")
+	}
+	if conf.IsSet(prefix + "_SERVER_NAME") {
+		t.ServerName = conf.GetString(prefix + "_SERVER_NAME")
+	}
+
+	return t
+}
@@ -0,0 +1,167 @@
+package authcache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	pbu "github.com/ibrat-muslim/blog_app_api_gateway/genproto/user_service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// expiredAtLayouts are the formats accepted for AuthPayload.ExpiredAt. Only
+// layouts with a zone are listed, so the expiry can't be misread.
+var expiredAtLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+}
+
+// Cache remembers successful token verifications for a short time, so
+// repeated requests with the same token don't each cost a VerifyToken call.
+// Errors are never cached, and concurrent lookups of the same key share a
+// single call.
+type Cache struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+	calls   map[string]*call
+}
+
+type entry struct {
+	key       string
+	payload   *pbu.AuthPayload
+	expiresAt time.Time
+}
+
+type call struct {
+	done    chan struct{}
+	payload *pbu.AuthPayload
+	err     error
+}
+
+// New creates a cache holding up to size entries for at most ttl each.
+// A zero ttl or size disables caching.
+func New(ttl time.Duration, size int) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		calls:   make(map[string]*call),
+	}
+}
+
+// Key identifies a verification. The token is hashed, so the cache never
+// holds raw credentials.
+func Key(token, resource, action string) string {
+	sum := sha256.Sum256([]byte(token + "\x00" + resource + "\x00" + action))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the cached payload of key or calls fetch to load it. The
+// returned payload is shared and must not be modified.
+func (c *Cache) Get(key string, fetch func() (*pbu.AuthPayload, error)) (*pbu.AuthPayload, error) {
+	if c.ttl <= 0 || c.size <= 0 {
+		return fetch()
+	}
+
+	c.mu.Lock()
+	if payload, ok := c.lookup(key, time.Now()); ok {
+		c.mu.Unlock()
+		return payload, nil
+	}
+
+	if cl, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		<-cl.done
+
+		// The shared call ran with another request's context. If that
+		// request went away, its cancellation says nothing about this one.
+		if isCanceled(cl.err) {
+			return fetch()
+		}
+		return cl.payload, cl.err
+	}
+
+	cl := &call{done: make(chan struct{})}
+	c.calls[key] = cl
+	c.mu.Unlock()
+
+	cl.payload, cl.err = fetch()
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	if cl.err == nil {
+		c.store(key, cl.payload, time.Now())
+	}
+	c.mu.Unlock()
+	close(cl.done)
+
+	return cl.payload, cl.err
+}
+
+func (c *Cache) lookup(key string, now time.Time) (*pbu.AuthPayload, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if !now.Before(e.expiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return e.payload, true
+}
+
+func (c *Cache) store(key string, payload *pbu.AuthPayload, now time.Time) {
+	expiresAt := now.Add(c.ttl)
+
+	// A token whose expiry can't be read is not cached at all, since it
+	// might otherwise be served after it expired.
+	tokenExpiresAt, ok := parseExpiredAt(payload.ExpiredAt)
+	if !ok {
+		return
+	}
+	if tokenExpiresAt.Before(expiresAt) {
+		expiresAt = tokenExpiresAt
+	}
+	if !now.Before(expiresAt) {
+		return
+	}
+
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+	}
+	c.entries[key] = c.order.PushFront(&entry{key: key, payload: payload, expiresAt: expiresAt})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+	}
+}
+
+func parseExpiredAt(s string) (time.Time, bool) {
+	for _, layout := range expiredAtLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func isCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || status.Code(err) == codes.Canceled
+}
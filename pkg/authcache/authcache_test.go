@@ -0,0 +1,340 @@
+package authcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pbu "github.com/ibrat-muslim/blog_app_api_gateway/genproto/user_service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// counter is a fetch func that returns payloads expiring at expiredAt and
+// counts its calls.
+type counter struct {
+	calls     int64
+	expiredAt string
+	err       error
+}
+
+func (f *counter) fetch() (*pbu.AuthPayload, error) {
+	atomic.AddInt64(&f.calls, 1)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &pbu.AuthPayload{Id: "1", ExpiredAt: f.expiredAt}, nil
+}
+
+func (f *counter) count() int64 {
+	return atomic.LoadInt64(&f.calls)
+}
+
+func inAnHour() string {
+	return time.Now().Add(time.Hour).Format(time.RFC3339Nano)
+}
+
+func TestCacheHit(t *testing.T) {
+	c := New(time.Minute, 10)
+	f := &counter{expiredAt: inAnHour()}
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get("a", f.fetch); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+	}
+	if f.count() != 1 {
+		t.Fatalf("fetch calls = %d, want 1", f.count())
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	for _, c := range []*Cache{New(0, 10), New(time.Minute, 0)} {
+		f := &counter{expiredAt: inAnHour()}
+		c.Get("a", f.fetch)
+		c.Get("a", f.fetch)
+		if f.count() != 2 {
+			t.Fatalf("fetch calls = %d, want 2", f.count())
+		}
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		expiredAt string
+		want      time.Time
+		cached    bool
+	}{
+		{
+			name:      "ttl before token expiry",
+			ttl:       time.Minute,
+			expiredAt: now.Add(time.Hour).Format(time.RFC3339Nano),
+			want:      now.Add(time.Minute),
+			cached:    true,
+		},
+		{
+			name:      "capped at token expiry",
+			ttl:       time.Hour,
+			expiredAt: now.Add(time.Minute).Format(time.RFC3339Nano),
+			want:      now.Add(time.Minute),
+			cached:    true,
+		},
+		{
+			name:      "time.Time string",
+			ttl:       time.Hour,
+			expiredAt: now.Round(0).Add(time.Minute).String(),
+			want:      now.Add(time.Minute),
+			cached:    true,
+		},
+		{
+			name:      "expired token",
+			ttl:       time.Hour,
+			expiredAt: now.Add(-time.Second).Format(time.RFC3339Nano),
+		},
+		{
+			name:      "unparseable expiry",
+			ttl:       time.Hour,
+			expiredAt: "tomorrow",
+		},
+		{
+			name:      "expiry without zone",
+			ttl:       time.Hour,
+			expiredAt: now.Add(time.Minute).Format("2006-01-02 15:04:05"),
+		},
+		{
+			name: "missing expiry",
+			ttl:  time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.ttl, 10)
+			f := &counter{expiredAt: tt.expiredAt}
+
+			c.Get("a", f.fetch)
+			c.Get("a", f.fetch)
+
+			el, ok := c.entries["a"]
+			if ok != tt.cached {
+				t.Fatalf("cached = %v, want %v", ok, tt.cached)
+			}
+			if !tt.cached {
+				if f.count() != 2 {
+					t.Fatalf("fetch calls = %d, want 2", f.count())
+				}
+				return
+			}
+
+			if f.count() != 1 {
+				t.Fatalf("fetch calls = %d, want 1", f.count())
+			}
+			if d := el.Value.(*entry).expiresAt.Sub(tt.want); d < -time.Second || d > time.Second {
+				t.Fatalf("expiresAt = %v, want %v", el.Value.(*entry).expiresAt, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c := New(time.Hour, 10)
+	f := &counter{expiredAt: time.Now().Add(50 * time.Millisecond).Format(time.RFC3339Nano)}
+
+	c.Get("a", f.fetch)
+	c.Get("a", f.fetch)
+	time.Sleep(60 * time.Millisecond)
+
+	f.expiredAt = inAnHour()
+	c.Get("a", f.fetch)
+	if f.count() != 2 {
+		t.Fatalf("fetch calls = %d, want 2", f.count())
+	}
+}
+
+func TestCacheErrorsNotCached(t *testing.T) {
+	c := New(time.Minute, 10)
+	f := &counter{err: status.Error(codes.Unauthenticated, "invalid token")}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get("a", f.fetch); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("Get() error = %v", err)
+		}
+	}
+	if f.count() != 2 {
+		t.Fatalf("fetch calls = %d, want 2", f.count())
+	}
+}
+
+func TestCacheLRU(t *testing.T) {
+	c := New(time.Minute, 2)
+	fetches := map[string]*counter{}
+	get := func(key string) {
+		if fetches[key] == nil {
+			fetches[key] = &counter{expiredAt: inAnHour()}
+		}
+		if _, err := c.Get(key, fetches[key].fetch); err != nil {
+			t.Fatalf("Get(%q) error = %v", key, err)
+		}
+	}
+
+	get("a")
+	get("b")
+	get("a") // a is now the most recently used
+	get("c") // evicts b
+
+	if len(c.entries) != 2 || c.order.Len() != 2 {
+		t.Fatalf("entries = %d, order = %d, want 2", len(c.entries), c.order.Len())
+	}
+
+	get("a")
+	get("c")
+	get("b")
+	want := map[string]int64{"a": 1, "b": 2, "c": 1}
+	for key, calls := range want {
+		if got := fetches[key].count(); got != calls {
+			t.Errorf("fetch calls of %q = %d, want %d", key, got, calls)
+		}
+	}
+}
+
+func TestCacheSingleFlight(t *testing.T) {
+	c := New(time.Minute, 10)
+
+	release := make(chan struct{})
+	var calls int64
+	fetch := func() (*pbu.AuthPayload, error) {
+		atomic.AddInt64(&calls, 1)
+		<-release
+		return &pbu.AuthPayload{Id: "1", ExpiredAt: inAnHour()}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if payload, err := c.Get("a", fetch); err != nil || payload.Id != "1" {
+				t.Errorf("Get() = %v, %v", payload, err)
+			}
+		}()
+	}
+
+	waitFor(t, func() bool { return atomic.LoadInt64(&calls) == 1 })
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("fetch calls = %d, want 1", calls)
+	}
+}
+
+func TestCacheSingleFlightCanceledLeader(t *testing.T) {
+	for _, canceled := range []error{context.Canceled, status.Error(codes.Canceled, "context canceled")} {
+		c := New(time.Minute, 10)
+
+		started, release := make(chan struct{}), make(chan struct{})
+		leader := func() (*pbu.AuthPayload, error) {
+			close(started)
+			<-release
+			return nil, canceled
+		}
+
+		leaderErr := make(chan error)
+		go func() {
+			_, err := c.Get("a", leader)
+			leaderErr <- err
+		}()
+		<-started
+
+		follower := &counter{expiredAt: inAnHour()}
+		followerDone := make(chan error)
+		go func() {
+			_, err := c.Get("a", follower.fetch)
+			followerDone <- err
+		}()
+
+		// The follower waits for the leader instead of fetching itself.
+		waitFor(t, func() bool {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.calls["a"] != nil
+		})
+		time.Sleep(10 * time.Millisecond)
+		if follower.count() != 0 {
+			t.Fatal("follower fetched while the leader was running")
+		}
+
+		close(release)
+		if err := <-leaderErr; !errors.Is(err, canceled) {
+			t.Fatalf("leader error = %v, want %v", err, canceled)
+		}
+
+		// The leader's cancellation is not handed to the follower, which
+		// retries with its own fetch.
+		if err := <-followerDone; err != nil {
+			t.Fatalf("follower error = %v", err)
+		}
+		if follower.count() != 1 {
+			t.Fatalf("follower fetch calls = %d, want 1", follower.count())
+		}
+	}
+}
+
+func TestCacheSingleFlightSharesErrors(t *testing.T) {
+	c := New(time.Minute, 10)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	leader := func() (*pbu.AuthPayload, error) {
+		close(started)
+		<-release
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	go c.Get("a", leader)
+	<-started
+
+	follower := &counter{expiredAt: inAnHour()}
+	done := make(chan error)
+	go func() {
+		_, err := c.Get("a", follower.fetch)
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if err := <-done; status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("follower error = %v, want Unauthenticated", err)
+	}
+	if follower.count() != 0 {
+		t.Fatalf("follower fetch calls = %d, want 0", follower.count())
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key("token", "posts", "create") == Key("token", "posts", "delete") {
+		t.Fatal("keys of different actions collide")
+	}
+	if Key("a", "bc", "d") == Key("ab", "c", "d") {
+		t.Fatal("keys of shifted fields collide")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
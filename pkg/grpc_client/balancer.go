@@ -0,0 +1,281 @@
+package grpc_client
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/serviceconfig"
+	"google.golang.org/grpc/status"
+
+	// registers the client side health check used by the balancers
+	_ "google.golang.org/grpc/health"
+)
+
+const (
+	LBRoundRobin   = "round_robin"
+	LBLeastRequest = "least_request"
+
+	roundRobinBalancer   = "gateway_round_robin"
+	leastRequestBalancer = "gateway_least_request"
+)
+
+func init() {
+	balancer.Register(&balancerBuilder{name: roundRobinBalancer})
+	balancer.Register(&balancerBuilder{name: leastRequestBalancer, leastRequest: true})
+}
+
+// serviceConfig selects the balancer of a service and turns on the
+// grpc.health.v1 watch, so replicas that report NOT_SERVING get no calls.
+// Replicas that do not implement the health service are treated as healthy.
+func serviceConfig(policy string, ejectionThreshold int, ejectionDuration time.Duration) (string, error) {
+	var name string
+	switch policy {
+	case "", LBRoundRobin:
+		name = roundRobinBalancer
+	case LBLeastRequest:
+		name = leastRequestBalancer
+	default:
+		return "", fmt.Errorf("unknown load balancing policy %q", policy)
+	}
+
+	js, err := json.Marshal(map[string]interface{}{
+		"loadBalancingConfig": []map[string]interface{}{{
+			name: lbConfig{
+				EjectionThreshold: ejectionThreshold,
+				EjectionDuration:  ejectionDuration.String(),
+			},
+		}},
+		"healthCheckConfig": map[string]string{"serviceName": ""},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(js), nil
+}
+
+type lbConfig struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+
+	EjectionThreshold int    `json:"ejectionThreshold"`
+	EjectionDuration  string `json:"ejectionDuration"`
+
+	ejectionDuration time.Duration
+}
+
+type balancerBuilder struct {
+	name         string
+	leastRequest bool
+}
+
+func (b *balancerBuilder) Name() string {
+	return b.name
+}
+
+func (b *balancerBuilder) ParseConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	cfg := &lbConfig{}
+	if err := json.Unmarshal(js, cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.EjectionDuration != "" {
+		d, err := time.ParseDuration(cfg.EjectionDuration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ejectionDuration: %w", err)
+		}
+		cfg.ejectionDuration = d
+	}
+
+	return cfg, nil
+}
+
+// Build reuses the base balancer for SubConn management and health
+// tracking, and plugs in a picker that also ejects failing replicas.
+func (b *balancerBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	pb := &pickerBuilder{
+		leastRequest: b.leastRequest,
+		endpoints:    make(map[balancer.SubConn]*endpoint),
+	}
+
+	return &ejectingBalancer{
+		Balancer: base.NewBalancerBuilder(b.name, pb, base.Config{HealthCheck: true}).Build(cc, opts),
+		pb:       pb,
+	}
+}
+
+type ejectingBalancer struct {
+	balancer.Balancer
+	pb *pickerBuilder
+}
+
+func (b *ejectingBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
+	if cfg, ok := s.BalancerConfig.(*lbConfig); ok {
+		b.pb.configure(cfg.EjectionThreshold, cfg.ejectionDuration)
+	}
+
+	return b.Balancer.UpdateClientConnState(s)
+}
+
+func (b *ejectingBalancer) ExitIdle() {
+	if ei, ok := b.Balancer.(balancer.ExitIdler); ok {
+		ei.ExitIdle()
+	}
+}
+
+// pickerBuilder keeps per-endpoint statistics across pickers, since a new
+// picker is built whenever a replica changes state.
+type pickerBuilder struct {
+	leastRequest bool
+
+	mu                sync.Mutex
+	ejectionThreshold int
+	ejectionDuration  time.Duration
+	endpoints         map[balancer.SubConn]*endpoint
+}
+
+func (pb *pickerBuilder) configure(threshold int, duration time.Duration) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	pb.ejectionThreshold = threshold
+	pb.ejectionDuration = duration
+}
+
+func (pb *pickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	endpoints := make(map[balancer.SubConn]*endpoint, len(info.ReadySCs))
+	ready := make([]*endpoint, 0, len(info.ReadySCs))
+	for sc := range info.ReadySCs {
+		ep, ok := pb.endpoints[sc]
+		if !ok {
+			ep = &endpoint{sc: sc}
+		}
+		endpoints[sc] = ep
+		ready = append(ready, ep)
+	}
+	pb.endpoints = endpoints
+
+	return &picker{
+		endpoints:         ready,
+		leastRequest:      pb.leastRequest,
+		ejectionThreshold: pb.ejectionThreshold,
+		ejectionDuration:  pb.ejectionDuration,
+		next:              uint32(rand.Intn(len(ready))),
+	}
+}
+
+// endpoint tracks the in-flight calls and consecutive Unavailable errors of
+// a single replica.
+type endpoint struct {
+	sc       balancer.SubConn
+	inflight int64
+
+	mu           sync.Mutex
+	failures     int
+	ejectedUntil time.Time
+}
+
+func (e *endpoint) ejected(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return now.Before(e.ejectedUntil)
+}
+
+// record ejects the replica for duration after threshold consecutive
+// Unavailable errors. Any other outcome means the replica answered, so the
+// count starts over.
+func (e *endpoint) record(err error, threshold int, duration time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if status.Code(err) != codes.Unavailable {
+		e.failures = 0
+		return
+	}
+
+	e.failures++
+	if threshold > 0 && e.failures >= threshold {
+		e.failures = 0
+		e.ejectedUntil = time.Now().Add(duration)
+	}
+}
+
+type picker struct {
+	endpoints         []*endpoint
+	leastRequest      bool
+	ejectionThreshold int
+	ejectionDuration  time.Duration
+	next              uint32
+}
+
+func (p *picker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	candidates := p.available(time.Now())
+
+	var ep *endpoint
+	if p.leastRequest {
+		ep = leastLoaded(candidates)
+	} else {
+		n := atomic.AddUint32(&p.next, 1)
+		ep = candidates[int(n%uint32(len(candidates)))]
+	}
+
+	atomic.AddInt64(&ep.inflight, 1)
+
+	return balancer.PickResult{
+		SubConn: ep.sc,
+		Done: func(info balancer.DoneInfo) {
+			atomic.AddInt64(&ep.inflight, -1)
+			ep.record(info.Err, p.ejectionThreshold, p.ejectionDuration)
+		},
+	}, nil
+}
+
+// available skips ejected replicas. When every replica is ejected, all of
+// them are used rather than failing the call outright.
+func (p *picker) available(now time.Time) []*endpoint {
+	candidates := make([]*endpoint, 0, len(p.endpoints))
+	for _, ep := range p.endpoints {
+		if !ep.ejected(now) {
+			candidates = append(candidates, ep)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return p.endpoints
+	}
+	return candidates
+}
+
+// leastLoaded picks two random replicas and returns the one with fewer
+// in-flight calls.
+func leastLoaded(candidates []*endpoint) *endpoint {
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+
+	i := rand.Intn(len(candidates))
+	j := rand.Intn(len(candidates) - 1)
+	if j >= i {
+		j++
+	}
+
+	a, b := candidates[i], candidates[j]
+	if atomic.LoadInt64(&b.inflight) < atomic.LoadInt64(&a.inflight) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,220 @@
+package grpc_client
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeSubConn struct {
+	balancer.SubConn
+	name string
+}
+
+func newTestPickerBuilder(leastRequest bool, threshold int) (*pickerBuilder, []balancer.SubConn) {
+	pb := &pickerBuilder{
+		leastRequest: leastRequest,
+		endpoints:    make(map[balancer.SubConn]*endpoint),
+	}
+	pb.configure(threshold, time.Minute)
+
+	return pb, []balancer.SubConn{
+		&fakeSubConn{name: "a"},
+		&fakeSubConn{name: "b"},
+		&fakeSubConn{name: "c"},
+	}
+}
+
+func buildPicker(pb *pickerBuilder, scs []balancer.SubConn) balancer.Picker {
+	ready := make(map[balancer.SubConn]base.SubConnInfo, len(scs))
+	for _, sc := range scs {
+		ready[sc] = base.SubConnInfo{}
+	}
+	return pb.Build(base.PickerBuildInfo{ReadySCs: ready})
+}
+
+// pick makes n calls and finishes each with the error returned by result
+// for the picked replica. It returns how often each replica was picked.
+func pick(t *testing.T, p balancer.Picker, n int, result func(name string) error) map[string]int {
+	t.Helper()
+
+	picked := make(map[string]int)
+	for i := 0; i < n; i++ {
+		res, err := p.Pick(balancer.PickInfo{})
+		if err != nil {
+			t.Fatalf("Pick() error = %v", err)
+		}
+
+		name := res.SubConn.(*fakeSubConn).name
+		picked[name]++
+		res.Done(balancer.DoneInfo{Err: result(name)})
+	}
+	return picked
+}
+
+func succeed(string) error { return nil }
+
+func TestPickerRoundRobin(t *testing.T) {
+	pb, scs := newTestPickerBuilder(false, 0)
+
+	picked := pick(t, buildPicker(pb, scs), 300, succeed)
+	for _, name := range []string{"a", "b", "c"} {
+		if picked[name] != 100 {
+			t.Fatalf("picks = %v, want 100 each", picked)
+		}
+	}
+}
+
+func TestPickerEjection(t *testing.T) {
+	pb, scs := newTestPickerBuilder(false, 3)
+	p := buildPicker(pb, scs)
+
+	unavailable := status.Error(codes.Unavailable, "down")
+	failA := func(name string) error {
+		if name == "a" {
+			return unavailable
+		}
+		return nil
+	}
+
+	// Two rounds leave a below the threshold.
+	if picked := pick(t, p, 6, failA); picked["a"] != 2 {
+		t.Fatalf("picks = %v", picked)
+	}
+	if picked := pick(t, p, 3, failA); picked["a"] != 1 {
+		t.Fatalf("picks = %v", picked)
+	}
+
+	picked := pick(t, p, 100, succeed)
+	if picked["a"] != 0 || picked["b"] != 50 || picked["c"] != 50 {
+		t.Fatalf("after ejection picks = %v", picked)
+	}
+
+	// Ejections survive a new picker, e.g. when another replica reconnects.
+	if picked := pick(t, buildPicker(pb, scs), 10, succeed); picked["a"] != 0 {
+		t.Fatalf("new picker picks = %v", picked)
+	}
+
+	// A replica that leaves and comes back starts over.
+	buildPicker(pb, scs[1:])
+	if picked := pick(t, buildPicker(pb, scs), 30, succeed); picked["a"] != 10 {
+		t.Fatalf("after reconnect picks = %v", picked)
+	}
+}
+
+func TestPickerEjectionNeedsConsecutiveFailures(t *testing.T) {
+	pb, scs := newTestPickerBuilder(false, 2)
+	p := buildPicker(pb, scs)
+
+	results := map[string][]error{"a": {
+		status.Error(codes.Unavailable, "down"),
+		status.Error(codes.NotFound, "answered"),
+		status.Error(codes.Unavailable, "down"),
+	}}
+	picked := pick(t, p, 9, func(name string) error {
+		if len(results[name]) == 0 {
+			return nil
+		}
+		err := results[name][0]
+		results[name] = results[name][1:]
+		return err
+	})
+	if picked["a"] != 3 {
+		t.Fatalf("picks = %v", picked)
+	}
+
+	if picked := pick(t, p, 3, succeed); picked["a"] != 1 {
+		t.Fatalf("a was ejected, picks = %v", picked)
+	}
+}
+
+func TestPickerAllEjected(t *testing.T) {
+	pb, scs := newTestPickerBuilder(false, 1)
+	p := buildPicker(pb, scs)
+
+	pick(t, p, 3, func(string) error { return status.Error(codes.Unavailable, "down") })
+	for _, ep := range pb.endpoints {
+		if !ep.ejected(time.Now()) {
+			t.Fatal("replica not ejected")
+		}
+	}
+
+	picked := pick(t, p, 30, succeed)
+	for _, name := range []string{"a", "b", "c"} {
+		if picked[name] != 10 {
+			t.Fatalf("picks = %v, want every replica", picked)
+		}
+	}
+}
+
+func TestPickerLeastRequest(t *testing.T) {
+	pb, scs := newTestPickerBuilder(true, 0)
+	p := buildPicker(pb, scs[:2])
+
+	// Leave calls on a in flight.
+	pb.endpoints[scs[0]].inflight = 5
+
+	if picked := pick(t, p, 50, succeed); picked["a"] != 0 {
+		t.Fatalf("busy replica picked, picks = %v", picked)
+	}
+}
+
+func TestPickerNoReadySubConns(t *testing.T) {
+	pb, _ := newTestPickerBuilder(false, 0)
+
+	_, err := buildPicker(pb, nil).Pick(balancer.PickInfo{})
+	if !errors.Is(err, balancer.ErrNoSubConnAvailable) {
+		t.Fatalf("Pick() error = %v, want %v", err, balancer.ErrNoSubConnAvailable)
+	}
+}
+
+func TestServiceConfig(t *testing.T) {
+	tests := []struct {
+		policy   string
+		balancer string
+		wantErr  bool
+	}{
+		{"", roundRobinBalancer, false},
+		{LBRoundRobin, roundRobinBalancer, false},
+		{LBLeastRequest, leastRequestBalancer, false},
+		{"random", "", true},
+	}
+
+	for _, tt := range tests {
+		js, err := serviceConfig(tt.policy, 5, 30*time.Second)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("serviceConfig(%q) error = nil, want error", tt.policy)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("serviceConfig(%q) error = %v", tt.policy, err)
+		}
+
+		var sc struct {
+			LoadBalancingConfig []map[string]json.RawMessage `json:"loadBalancingConfig"`
+		}
+		if err := json.Unmarshal([]byte(js), &sc); err != nil {
+			t.Fatalf("decode %s: %v", js, err)
+		}
+		raw, ok := sc.LoadBalancingConfig[0][tt.balancer]
+		if !ok {
+			t.Fatalf("serviceConfig(%q) = %s, want balancer %q", tt.policy, js, tt.balancer)
+		}
+
+		cfg, err := (&balancerBuilder{name: tt.balancer}).ParseConfig(raw)
+		if err != nil {
+			t.Fatalf("ParseConfig() error = %v", err)
+		}
+		if lc := cfg.(*lbConfig); lc.EjectionThreshold != 5 || lc.ejectionDuration != 30*time.Second {
+			t.Fatalf("ParseConfig() = %+v", lc)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package grpc_client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeInvoker answers every call with err and counts the calls.
+type fakeInvoker struct {
+	err   error
+	calls int
+}
+
+func (f *fakeInvoker) invoke(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+	f.calls++
+	return f.err
+}
+
+func TestCircuitBreakerOpensAndCloses(t *testing.T) {
+	b := newCircuitBreaker(2, 20*time.Millisecond)
+	call := b.unaryClientInterceptor("post")
+	inv := &fakeInvoker{err: status.Error(codes.Unavailable, "down")}
+
+	for i := 0; i < 2; i++ {
+		if err := call(context.Background(), "/genproto.PostService/Get", nil, nil, nil, inv.invoke); status.Code(err) != codes.Unavailable {
+			t.Fatalf("call %d: error = %v", i, err)
+		}
+	}
+	if got := b.State(); got != BreakerOpen {
+		t.Fatalf("State() = %q, want %q", got, BreakerOpen)
+	}
+
+	// Open: calls fail fast without reaching the service.
+	err := call(context.Background(), "/genproto.PostService/Get", nil, nil, nil, inv.invoke)
+	if status.Code(err) != codes.Unavailable || inv.calls != 2 {
+		t.Fatalf("open breaker: error = %v, calls = %d", err, inv.calls)
+	}
+
+	// Health checks always go through.
+	if call(context.Background(), healthCheckMethod, nil, nil, nil, inv.invoke); inv.calls != 3 {
+		t.Fatalf("health check not passed through, calls = %d", inv.calls)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if got := b.State(); got != BreakerHalfOpen {
+		t.Fatalf("State() = %q, want %q", got, BreakerHalfOpen)
+	}
+
+	// A failed probe opens the breaker again.
+	call(context.Background(), "/genproto.PostService/Get", nil, nil, nil, inv.invoke)
+	if got := b.State(); got != BreakerOpen {
+		t.Fatalf("after failed probe State() = %q, want %q", got, BreakerOpen)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	inv.err = nil
+	if err := call(context.Background(), "/genproto.PostService/Get", nil, nil, nil, inv.invoke); err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	if got := b.State(); got != BreakerClosed {
+		t.Fatalf("after successful probe State() = %q, want %q", got, BreakerClosed)
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	b := newCircuitBreaker(1, 10*time.Millisecond)
+	b.record(status.Error(codes.Unavailable, "down"))
+	time.Sleep(15 * time.Millisecond)
+
+	call := b.unaryClientInterceptor("post")
+	started, release := make(chan struct{}), make(chan struct{})
+	probe := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		close(started)
+		<-release
+		return nil
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- call(context.Background(), "/genproto.PostService/Get", nil, nil, nil, probe)
+	}()
+	<-started
+
+	inv := &fakeInvoker{}
+	err := call(context.Background(), "/genproto.PostService/Get", nil, nil, nil, inv.invoke)
+	if status.Code(err) != codes.Unavailable || inv.calls != 0 {
+		t.Fatalf("call during probe: error = %v, calls = %d", err, inv.calls)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	if err := call(context.Background(), "/genproto.PostService/Get", nil, nil, nil, inv.invoke); err != nil || inv.calls != 1 {
+		t.Fatalf("after probe: error = %v, calls = %d", err, inv.calls)
+	}
+}
+
+func TestCircuitBreakerIgnoresCanceled(t *testing.T) {
+	b := newCircuitBreaker(2, time.Minute)
+
+	b.record(status.Error(codes.Unavailable, "down"))
+	b.record(status.Error(codes.Canceled, "client went away"))
+	if got := b.State(); got != BreakerClosed {
+		t.Fatalf("State() = %q, want %q", got, BreakerClosed)
+	}
+
+	// Canceled did not reset the count either.
+	b.record(status.Error(codes.DeadlineExceeded, "slow"))
+	if got := b.State(); got != BreakerOpen {
+		t.Fatalf("State() = %q, want %q", got, BreakerOpen)
+	}
+
+	for i := 0; i < 3; i++ {
+		b.record(status.Error(codes.Canceled, "client went away"))
+	}
+	if got := b.State(); got != BreakerOpen {
+		t.Fatalf("Canceled closed the breaker, State() = %q", got)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := newCircuitBreaker(0, time.Minute)
+	call := b.unaryClientInterceptor("post")
+	inv := &fakeInvoker{err: status.Error(codes.Unavailable, "down")}
+
+	for i := 0; i < 5; i++ {
+		call(context.Background(), "/genproto.PostService/Get", nil, nil, nil, inv.invoke)
+	}
+	if inv.calls != 5 || b.State() != BreakerClosed {
+		t.Fatalf("calls = %d, state = %q", inv.calls, b.State())
+	}
+}
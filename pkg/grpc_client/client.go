@@ -1,69 +1,225 @@
 package grpc_client
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/ibrat-muslim/blog_app_api_gateway/config"
+	pbn "github.com/ibrat-muslim/blog_app_api_gateway/genproto/notification_service"
 	pbp "github.com/ibrat-muslim/blog_app_api_gateway/genproto/post_service"
 	pbu "github.com/ibrat-muslim/blog_app_api_gateway/genproto/user_service"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/metrics"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/requestid"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+var errClientClosed = errors.New("grpc client is closed")
+
 type GrpcClientI interface {
 	UserService() pbu.UserServiceClient
 	AuthService() pbu.AuthServiceClient
 	PostService() pbp.PostServiceClient
 	CategoryService() pbp.CategoryServiceClient
+	NotificationService() pbn.NotificationServiceClient
+	CheckHealth(ctx context.Context) []ServiceHealth
+	Close() error
 }
 
 type GrpcClient struct {
-	cfg         config.Config
-	connections map[string]interface{}
+	conns map[string]*serviceConn
+	// ordered holds the services in the order they are configured, which
+	// is also the order they are reported on readiness.
+	ordered []*serviceConn
 }
 
+// New prepares a connection for every service in cfg.Services. Required
+// services are dialed right away, so readiness probes them from the start;
+// optional ones are only dialed on first use.
 func New(cfg config.Config) (GrpcClientI, error) {
-	connUserService, err := grpc.Dial(
-		fmt.Sprintf("%s%s", cfg.UserServiceHost, cfg.UserServiceGrpcPort),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+	g := &GrpcClient{conns: make(map[string]*serviceConn)}
+
+	for _, svc := range cfg.Services {
+		sc, err := newServiceConn(svc, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", svc.Name, err)
+		}
+
+		if svc.Required {
+			if _, ok := sc.connect().(errConn); ok {
+				return nil, fmt.Errorf("%s: %v", svc.Name, sc.err)
+			}
+		}
+
+		g.conns[svc.Name] = sc
+		g.ordered = append(g.ordered, sc)
+	}
+
+	return g, nil
+}
+
+// serviceClient binds a configured service to the constructor of its typed
+// client.
+type serviceClient[T any] struct {
+	name      string
+	newClient func(grpc.ClientConnInterface) T
+}
+
+var (
+	userService         = serviceClient[pbu.UserServiceClient]{config.UserService, pbu.NewUserServiceClient}
+	authService         = serviceClient[pbu.AuthServiceClient]{config.AuthService, pbu.NewAuthServiceClient}
+	postService         = serviceClient[pbp.PostServiceClient]{config.PostService, pbp.NewPostServiceClient}
+	categoryService     = serviceClient[pbp.CategoryServiceClient]{config.CategoryService, pbp.NewCategoryServiceClient}
+	notificationService = serviceClient[pbn.NotificationServiceClient]{config.NotificationService, pbn.NewNotificationServiceClient}
+)
+
+func (s serviceClient[T]) get(g *GrpcClient) T {
+	sc, ok := g.conns[s.name]
+	if !ok {
+		return s.newClient(errConn{err: fmt.Errorf("%s is not configured", s.name)})
+	}
+	return s.newClient(sc.connect())
+}
+
+func (g *GrpcClient) UserService() pbu.UserServiceClient {
+	return userService.get(g)
+}
+
+func (g *GrpcClient) AuthService() pbu.AuthServiceClient {
+	return authService.get(g)
+}
+
+func (g *GrpcClient) PostService() pbp.PostServiceClient {
+	return postService.get(g)
+}
+
+func (g *GrpcClient) CategoryService() pbp.CategoryServiceClient {
+	return categoryService.get(g)
+}
+
+func (g *GrpcClient) NotificationService() pbn.NotificationServiceClient {
+	return notificationService.get(g)
+}
+
+// Close tears down every connection that has been dialed. All connections
+// are closed even if some of them fail, and the first error is returned.
+func (g *GrpcClient) Close() error {
+	var result error
+	for _, sc := range g.ordered {
+		if err := sc.close(); err != nil && result == nil {
+			result = fmt.Errorf("close %s connection: %w", sc.name, err)
+		}
+	}
+
+	return result
+}
+
+// serviceConn is the connection to a single service with its own circuit
+// breaker. Optional services are dialed on first use.
+type serviceConn struct {
+	name     string
+	target   string
+	required bool
+	opts     []grpc.DialOption
+	breaker  *circuitBreaker
+
+	mu     sync.Mutex
+	dialed bool
+	conn   *grpc.ClientConn
+	err    error
+}
+
+// newServiceConn builds the dial options of a service. Retries run inside
+// the breaker, so it only sees the outcome of the whole call, and each
+// attempt is balanced across the service's replicas separately.
+func newServiceConn(svc config.Service, cfg config.Config) (*serviceConn, error) {
+	creds, err := transportCredentials(svc.TLS)
 	if err != nil {
-		return nil, fmt.Errorf("user service dial host: %s port:%s err: %v",
-			cfg.UserServiceHost, cfg.UserServiceGrpcPort, err)
+		return nil, err
 	}
 
-	connPostService, err := grpc.Dial(
-		fmt.Sprintf("%s%s", cfg.PostServiceHost, cfg.PostServiceGrpcPort),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+	sc, err := serviceConfig(svc.LBPolicy, cfg.GrpcEjectionThreshold, cfg.GrpcEjectionDuration)
 	if err != nil {
-		return nil, fmt.Errorf("post service dial host: %s port:%s err: %v",
-			cfg.PostServiceHost, cfg.PostServiceGrpcPort, err)
+		return nil, err
 	}
 
-	return &GrpcClient{
-		cfg: cfg,
-		connections: map[string]interface{}{
-			"user_service":     pbu.NewUserServiceClient(connUserService),
-			"auth_service":     pbu.NewAuthServiceClient(connUserService),
-			"post_service":     pbp.NewPostServiceClient(connPostService),
-			"category_service": pbp.NewCategoryServiceClient(connPostService),
+	breaker := newCircuitBreaker(cfg.GrpcBreakerThreshold, cfg.GrpcBreakerOpenTimeout)
+
+	return &serviceConn{
+		name:     svc.Name,
+		target:   target(svc.Addresses, svc.Host, svc.GrpcPort),
+		required: svc.Required,
+		breaker:  breaker,
+		opts: []grpc.DialOption{
+			grpc.WithTransportCredentials(creds),
+			grpc.WithResolvers(staticResolverBuilder{}),
+			grpc.WithDefaultServiceConfig(sc),
+			grpc.WithChainUnaryInterceptor(
+				otelgrpc.UnaryClientInterceptor(),
+				metrics.UnaryClientInterceptor(),
+				requestid.UnaryClientInterceptor(),
+				breaker.unaryClientInterceptor(svc.Name),
+				retryUnaryClientInterceptor(RetryPolicy(svc.Retry)),
+			),
 		},
 	}, nil
 }
 
-func (g *GrpcClient) UserService() pbu.UserServiceClient {
-	return g.connections["user_service"].(pbu.UserServiceClient)
+// connect dials the service on first use. If dialing fails, every call
+// made through the returned connection fails with Unavailable.
+func (sc *serviceConn) connect() grpc.ClientConnInterface {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if !sc.dialed {
+		sc.dialed = true
+		sc.conn, sc.err = grpc.Dial(sc.target, sc.opts...)
+	}
+
+	if sc.err != nil {
+		return errConn{err: fmt.Errorf("%s: %v", sc.name, sc.err)}
+	}
+	return sc.conn
 }
 
-func (g *GrpcClient) AuthService() pbu.AuthServiceClient {
-	return g.connections["auth_service"].(pbu.AuthServiceClient)
+// state returns the connection without dialing. dialed is false for
+// services that have not been used yet.
+func (sc *serviceConn) state() (conn *grpc.ClientConn, dialed bool, err error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	return sc.conn, sc.dialed, sc.err
 }
 
-func (g *GrpcClient) PostService() pbp.PostServiceClient {
-	return g.connections["post_service"].(pbp.PostServiceClient)
+// close also prevents a service that was never used from being dialed
+// afterwards.
+func (sc *serviceConn) close() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if !sc.dialed {
+		sc.dialed = true
+		sc.err = errClientClosed
+	}
+
+	if sc.conn == nil {
+		return nil
+	}
+	return sc.conn.Close()
 }
 
-func (g *GrpcClient) CategoryService() pbp.CategoryServiceClient {
-	return g.connections["category_service"].(pbp.CategoryServiceClient)
+type errConn struct {
+	err error
+}
+
+func (c errConn) Invoke(context.Context, string, interface{}, interface{}, ...grpc.CallOption) error {
+	return status.Error(codes.Unavailable, c.err.Error())
+}
+
+func (c errConn) NewStream(context.Context, *grpc.StreamDesc, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, status.Error(codes.Unavailable, c.err.Error())
 }
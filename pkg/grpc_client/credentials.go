@@ -0,0 +1,55 @@
+package grpc_client
+
+import (
+	"context"
+	"net"
+
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
+	"github.com/ibrat-muslim/blog_app_api_gateway/pkg/tlsutil"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// transportCredentials uses TLS unless insecure mode is explicitly enabled.
+func transportCredentials(tlsCfg config.TLS) (credentials.TransportCredentials, error) {
+	if tlsCfg.Insecure {
+		return insecure.NewCredentials(), nil
+	}
+
+	reloader, err := tlsutil.NewReloader(tlsCfg.CertFile, tlsCfg.KeyFile, tlsCfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reloadingCredentials{
+		TransportCredentials: credentials.NewTLS(reloader.ClientConfig(tlsCfg.ServerName)),
+		reloader:             reloader,
+		serverName:           tlsCfg.ServerName,
+	}, nil
+}
+
+// reloadingCredentials builds fresh TLS credentials for every handshake, so
+// rotated files are used with the standard certificate verification,
+// including the host name and IP SAN checks.
+type reloadingCredentials struct {
+	credentials.TransportCredentials
+	reloader   *tlsutil.Reloader
+	serverName string
+}
+
+func (c *reloadingCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return credentials.NewTLS(c.reloader.ClientConfig(c.serverName)).ClientHandshake(ctx, authority, conn)
+}
+
+func (c *reloadingCredentials) Clone() credentials.TransportCredentials {
+	return &reloadingCredentials{
+		TransportCredentials: c.TransportCredentials.Clone(),
+		reloader:             c.reloader,
+		serverName:           c.serverName,
+	}
+}
+
+func (c *reloadingCredentials) OverrideServerName(serverName string) error {
+	c.serverName = serverName
+	return c.TransportCredentials.OverrideServerName(serverName)
+}
@@ -0,0 +1,94 @@
+package grpc_client
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceHealth describes the reachability of a single downstream service.
+type ServiceHealth struct {
+	Name     string
+	Target   string
+	State    string
+	Required bool
+	// Dialed is false for optional services that have not been used yet.
+	// They are not probed, so their health is unknown.
+	Dialed  bool
+	Healthy bool
+	Breaker string
+	Error   string
+}
+
+// CheckHealth probes every dialed service concurrently. Required services
+// are always dialed; optional ones that have not been used yet are reported
+// without connecting them, so readiness probes don't defeat lazy dialing. A service is considered healthy when it answers the
+// standard grpc.health.v1 check with SERVING, or when it does not implement
+// that protocol but the call still reached it, and its circuit breaker is
+// not open.
+func (g *GrpcClient) CheckHealth(ctx context.Context) []ServiceHealth {
+	result := make([]ServiceHealth, len(g.ordered))
+
+	var wg sync.WaitGroup
+	for i, sc := range g.ordered {
+		wg.Add(1)
+		go func(i int, sc *serviceConn) {
+			defer wg.Done()
+			result[i] = checkConn(ctx, sc)
+		}(i, sc)
+	}
+	wg.Wait()
+
+	return result
+}
+
+func checkConn(ctx context.Context, sc *serviceConn) ServiceHealth {
+	health := ServiceHealth{
+		Name:     sc.name,
+		Target:   sc.target,
+		Required: sc.required,
+		Breaker:  sc.breaker.State(),
+	}
+
+	conn, dialed, err := sc.state()
+	if !dialed {
+		health.State = "NOT_DIALED"
+		return health
+	}
+
+	health.Dialed = true
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+
+	if conn.GetState() == connectivity.Idle {
+		conn.Connect()
+	}
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	switch {
+	case err == nil:
+		health.Healthy = resp.Status == healthpb.HealthCheckResponse_SERVING
+		if !health.Healthy {
+			health.Error = "service status is " + resp.Status.String()
+		}
+	case status.Code(err) == codes.Unimplemented:
+		health.Healthy = true
+	default:
+		health.Error = err.Error()
+	}
+
+	health.State = conn.GetState().String()
+
+	if health.Healthy && health.Breaker == BreakerOpen {
+		health.Healthy = false
+		health.Error = "circuit breaker is open"
+	}
+
+	return health
+}
@@ -0,0 +1,67 @@
+package grpc_client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc/resolver"
+)
+
+const staticScheme = "static"
+
+// target builds the dial target of a service. A comma separated address
+// list goes through the static resolver, a target with a scheme such as
+// dns:///service:port is handed to gRPC unchanged, and without addresses
+// the host and port pair is used.
+func target(addresses, host, port string) string {
+	switch {
+	case addresses == "":
+		return host + port
+	case strings.Contains(addresses, "://"):
+		return addresses
+	}
+
+	return staticScheme + ":///" + addresses
+}
+
+// staticResolverBuilder resolves static:///host:port,host:port to a fixed
+// list of backend addresses. Each address carries its own host as the TLS
+// server name, since the authority of such a target is not a host name.
+type staticResolverBuilder struct{}
+
+func (staticResolverBuilder) Build(t resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	var addrs []resolver.Address
+	for _, addr := range strings.Split(strings.TrimPrefix(t.URL.Path, "/"), ",") {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
+		}
+
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", addr, err)
+		}
+		addrs = append(addrs, resolver.Address{Addr: addr, ServerName: host})
+	}
+
+	if len(addrs) == 0 {
+		return nil, errors.New("static target has no addresses")
+	}
+
+	if err := cc.UpdateState(resolver.State{Addresses: addrs}); err != nil {
+		return nil, err
+	}
+
+	return staticResolver{}, nil
+}
+
+func (staticResolverBuilder) Scheme() string {
+	return staticScheme
+}
+
+type staticResolver struct{}
+
+func (staticResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (staticResolver) Close() {}
@@ -0,0 +1,95 @@
+package grpc_client
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/resolver"
+)
+
+func TestTarget(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses string
+		host      string
+		port      string
+		want      string
+	}{
+		{"host and port", "", "user_service", ":8000", "user_service:8000"},
+		{"address list", "10.0.0.1:8000,10.0.0.2:8000", "user_service", ":8000", "static:///10.0.0.1:8000,10.0.0.2:8000"},
+		{"single address", "users.internal:8000", "", "", "static:///users.internal:8000"},
+		{"dns target", "dns:///user_service:8000", "user_service", ":8000", "dns:///user_service:8000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := target(tt.addresses, tt.host, tt.port); got != tt.want {
+				t.Fatalf("target() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeResolverClientConn struct {
+	resolver.ClientConn
+	state resolver.State
+}
+
+func (cc *fakeResolverClientConn) UpdateState(s resolver.State) error {
+	cc.state = s
+	return nil
+}
+
+func TestStaticResolver(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		want    []resolver.Address
+		wantErr bool
+	}{
+		{
+			name:   "server name per address",
+			target: "static:///10.0.0.1:8000, users.internal:8000,[::1]:8000,",
+			want: []resolver.Address{
+				{Addr: "10.0.0.1:8000", ServerName: "10.0.0.1"},
+				{Addr: "users.internal:8000", ServerName: "users.internal"},
+				{Addr: "[::1]:8000", ServerName: "::1"},
+			},
+		},
+		{
+			name:    "missing port",
+			target:  "static:///10.0.0.1",
+			wantErr: true,
+		},
+		{
+			name:    "no addresses",
+			target:  "static:///,",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cc := &fakeResolverClientConn{}
+			_, err = staticResolverBuilder{}.Build(resolver.Target{URL: *u}, cc, resolver.BuildOptions{})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Build() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			if !reflect.DeepEqual(cc.state.Addresses, tt.want) {
+				t.Fatalf("addresses = %+v, want %+v", cc.state.Addresses, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package grpc_client
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy describes how idempotent calls are retried after the
+// service reported Unavailable.
+type RetryPolicy struct {
+	// Methods are the bare method names that are safe to retry, like "Get".
+	Methods     []string
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// retryUnaryClientInterceptor retries calls of policy.Methods with full
+// jitter exponential backoff. The retries share the deadline of the call.
+func retryUnaryClientInterceptor(policy RetryPolicy) grpc.UnaryClientInterceptor {
+	methods := make(map[string]bool, len(policy.Methods))
+	for _, m := range policy.Methods {
+		methods[strings.TrimSpace(m)] = true
+	}
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if policy.MaxAttempts <= 1 || !methods[methodName(method)] {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		for attempt := 0; ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if status.Code(err) != codes.Unavailable || attempt+1 >= policy.MaxAttempts {
+				return err
+			}
+
+			timer := time.NewTimer(backoffDelay(policy, attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// backoffDelay picks a random delay up to Backoff doubled attempt times,
+// capped at MaxBackoff.
+func backoffDelay(policy RetryPolicy, attempt int) time.Duration {
+	limit := policy.MaxBackoff
+	if attempt < 32 {
+		if d := policy.Backoff << attempt; d > 0 && d < limit {
+			limit = d
+		}
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(limit)))
+}
+
+// methodName returns "Get" for "/genproto.PostService/Get".
+func methodName(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+}
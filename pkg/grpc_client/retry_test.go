@@ -0,0 +1,145 @@
+package grpc_client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryUnaryClientInterceptor(t *testing.T) {
+	policy := RetryPolicy{
+		Methods:     []string{"Get", " GetAll"},
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		MaxBackoff:  2 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		errs      []codes.Code
+		wantCalls int
+		wantCode  codes.Code
+	}{
+		{
+			name:      "success",
+			method:    "/genproto.PostService/Get",
+			wantCalls: 1,
+			wantCode:  codes.OK,
+		},
+		{
+			name:      "recovers after retry",
+			method:    "/genproto.PostService/GetAll",
+			errs:      []codes.Code{codes.Unavailable, codes.Unavailable},
+			wantCalls: 3,
+			wantCode:  codes.OK,
+		},
+		{
+			name:      "gives up after max attempts",
+			method:    "/genproto.PostService/Get",
+			errs:      []codes.Code{codes.Unavailable, codes.Unavailable, codes.Unavailable, codes.Unavailable},
+			wantCalls: 3,
+			wantCode:  codes.Unavailable,
+		},
+		{
+			name:      "other errors are not retried",
+			method:    "/genproto.PostService/Get",
+			errs:      []codes.Code{codes.DeadlineExceeded},
+			wantCalls: 1,
+			wantCode:  codes.DeadlineExceeded,
+		},
+		{
+			name:      "method not listed",
+			method:    "/genproto.PostService/Create",
+			errs:      []codes.Code{codes.Unavailable},
+			wantCalls: 1,
+			wantCode:  codes.Unavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.errs
+			calls := 0
+			invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+				calls++
+				if len(errs) == 0 {
+					return nil
+				}
+				code := errs[0]
+				errs = errs[1:]
+				return status.Error(code, "failed")
+			}
+
+			err := retryUnaryClientInterceptor(policy)(context.Background(), tt.method, nil, nil, nil, invoker)
+			if got := status.Code(err); got != tt.wantCode {
+				t.Errorf("code = %v, want %v", got, tt.wantCode)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryStopsAtDeadline(t *testing.T) {
+	policy := RetryPolicy{
+		Methods:     []string{"Get"},
+		MaxAttempts: 10,
+		Backoff:     time.Hour,
+		MaxBackoff:  time.Hour,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	inv := &fakeInvoker{err: status.Error(codes.Unavailable, "down")}
+	start := time.Now()
+	err := retryUnaryClientInterceptor(policy)(ctx, "/genproto.PostService/Get", nil, nil, nil, inv.invoke)
+
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("error = %v", err)
+	}
+	// The first backoff is drawn from [0, 1h), so the deadline ends it.
+	if inv.calls > 2 || time.Since(start) > time.Second {
+		t.Fatalf("calls = %d after %v", inv.calls, time.Since(start))
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	policy := RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+
+	tests := []struct {
+		attempt int
+		limit   time.Duration
+	}{
+		{0, 10 * time.Millisecond},
+		{1, 20 * time.Millisecond},
+		{2, 40 * time.Millisecond},
+		{3, 50 * time.Millisecond},
+		{40, 50 * time.Millisecond},
+		{100, 50 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 200; i++ {
+			if d := backoffDelay(policy, tt.attempt); d < 0 || d >= tt.limit {
+				t.Fatalf("backoffDelay(attempt %d) = %v, want [0, %v)", tt.attempt, d, tt.limit)
+			}
+		}
+	}
+
+	if d := backoffDelay(RetryPolicy{}, 3); d != 0 {
+		t.Fatalf("backoffDelay() without backoff = %v, want 0", d)
+	}
+}
+
+func TestMethodName(t *testing.T) {
+	if got := methodName("/genproto.PostService/Get"); got != "Get" {
+		t.Fatalf("methodName() = %q, want %q", got, "Get")
+	}
+}
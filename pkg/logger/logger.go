@@ -1,11 +1,31 @@
 package logger
 
-import "github.com/sirupsen/logrus"
+import (
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
+	"github.com/sirupsen/logrus"
+)
 
-func New() *logrus.Logger {
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+func New(cfg config.Config) *logrus.Logger {
 	log := logrus.New()
-	log.SetFormatter(&logrus.JSONFormatter{PrettyPrint: true})
-	log.SetLevel(logrus.InfoLevel)
+
+	if cfg.LogFormat == FormatText {
+		log.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	} else {
+		log.SetFormatter(&logrus.JSONFormatter{PrettyPrint: cfg.LogPrettyPrint})
+	}
+
+	level, err := logrus.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		level = logrus.InfoLevel
+	}
+	log.SetLevel(level)
+
+	log.AddHook(redactHook{})
 
 	return log
 }
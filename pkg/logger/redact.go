@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are field, header and JSON key names whose values never
+// reach the logs. Keys are compared case-insensitively.
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"password":      true,
+	"code":          true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+}
+
+func isSensitive(key string) bool {
+	return sensitiveKeys[strings.ToLower(key)]
+}
+
+// redactHook masks sensitive fields of every entry before it is written.
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (redactHook) Fire(entry *logrus.Entry) error {
+	for key := range entry.Data {
+		if isSensitive(key) {
+			entry.Data[key] = redacted
+		}
+	}
+	return nil
+}
+
+// RedactHeaders flattens headers for logging, masking sensitive ones.
+func RedactHeaders(headers http.Header) map[string]string {
+	result := make(map[string]string, len(headers))
+	for key, values := range headers {
+		if isSensitive(key) {
+			result[key] = redacted
+			continue
+		}
+		result[key] = strings.Join(values, ", ")
+	}
+	return result
+}
+
+// RedactJSON masks sensitive keys at any depth of a JSON document. Bodies
+// that are not valid JSON are dropped entirely, since they cannot be
+// inspected safely.
+func RedactJSON(body []byte) string {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return redacted
+	}
+
+	result, err := json.Marshal(redactValue(doc))
+	if err != nil {
+		return redacted
+	}
+	return string(result)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if isSensitive(key) {
+				value[key] = redacted
+				continue
+			}
+			value[key] = redactValue(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactValue(item)
+		}
+	}
+	return v
+}
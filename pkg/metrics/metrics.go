@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const namespace = "api_gateway"
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests handled, by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests, by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	httpRequestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "Number of HTTP requests currently being handled, by method and route.",
+	}, []string{"method", "route"})
+
+	grpcClientCallsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_client_calls_total",
+		Help:      "Number of gRPC calls made to downstream services, by service, method and status code.",
+	}, []string{"service", "method", "code"})
+
+	grpcClientCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_client_call_duration_seconds",
+		Help:      "Latency of gRPC calls made to downstream services, by service and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "method"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		httpRequestsTotal,
+		httpRequestDuration,
+		httpRequestsInFlight,
+		grpcClientCallsTotal,
+		grpcClientCallDuration,
+	)
+}
+
+// GinMiddleware records request counts, latencies and in-flight requests.
+// Requests are labeled by route template rather than raw path to keep the
+// number of series bounded.
+func GinMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := ctx.Request.Method
+
+		inFlight := httpRequestsInFlight.WithLabelValues(method, route)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		ctx.Next()
+
+		code := strconv.Itoa(ctx.Writer.Status())
+		httpRequestsTotal.WithLabelValues(method, route, code).Inc()
+		httpRequestDuration.WithLabelValues(method, route, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// UnaryClientInterceptor records latency and status codes of outgoing gRPC calls.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{},
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		service, name := splitMethodName(method)
+		grpcClientCallsTotal.WithLabelValues(service, name, status.Code(err).String()).Inc()
+		grpcClientCallDuration.WithLabelValues(service, name).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+// splitMethodName splits "/package.Service/Method" into "Service" and "Method".
+func splitMethodName(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+
+	service, method := "unknown", "unknown"
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		service, method = fullMethod[:i], fullMethod[i+1:]
+	}
+	if i := strings.LastIndex(service, "."); i >= 0 {
+		service = service[i+1:]
+	}
+
+	return service, method
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRoute is the policy key applied to routes without a policy of their own.
+const DefaultRoute = "*"
+
+// Limit allows Requests requests per Period.
+type Limit struct {
+	Requests int64
+	Period   time.Duration
+}
+
+// Policies maps "METHOD /route/template" to the limit of that route.
+type Policies map[string]Limit
+
+// Parse reads a comma separated list of policies in the form
+// "POST /v1/auth/login=10/1m". The route "*" sets the default limit.
+func Parse(s string) (Policies, error) {
+	policies := make(Policies)
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		route, rate, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit policy %q", item)
+		}
+
+		limit, err := parseLimit(strings.TrimSpace(rate))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit policy %q: %w", item, err)
+		}
+
+		policies[strings.Join(strings.Fields(route), " ")] = limit
+	}
+
+	return policies, nil
+}
+
+func parseLimit(s string) (Limit, error) {
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate must be in the form requests/period")
+	}
+
+	n, err := strconv.ParseInt(requests, 10, 64)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("requests must be a positive number")
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("period must be a positive duration")
+	}
+
+	return Limit{Requests: n, Period: d}, nil
+}
+
+// Lookup returns the limit of the route, falling back to the default one.
+func (p Policies) Lookup(method, route string) (string, Limit, bool) {
+	key := method + " " + route
+	if limit, ok := p[key]; ok {
+		return key, limit, true
+	}
+
+	limit, ok := p[DefaultRoute]
+	return key, limit, ok
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    Policies
+		wantErr bool
+	}{
+		{
+			name: "routes and default",
+			in:   " POST  /v1/auth/login = 10/1m, GET /v1/posts=100/30s ,*=120/1m,",
+			want: Policies{
+				"POST /v1/auth/login": {Requests: 10, Period: time.Minute},
+				"GET /v1/posts":       {Requests: 100, Period: 30 * time.Second},
+				DefaultRoute:          {Requests: 120, Period: time.Minute},
+			},
+		},
+		{name: "empty", in: "", want: Policies{}},
+		{name: "missing rate", in: "POST /v1/auth/login", wantErr: true},
+		{name: "missing period", in: "*=10", wantErr: true},
+		{name: "zero requests", in: "*=0/1m", wantErr: true},
+		{name: "negative requests", in: "*=-1/1m", wantErr: true},
+		{name: "invalid requests", in: "*=ten/1m", wantErr: true},
+		{name: "invalid period", in: "*=10/minute", wantErr: true},
+		{name: "zero period", in: "*=10/0s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	p := Policies{
+		"POST /v1/auth/login": {Requests: 10, Period: time.Minute},
+		DefaultRoute:          {Requests: 120, Period: time.Minute},
+	}
+
+	key, limit, ok := p.Lookup("POST", "/v1/auth/login")
+	if !ok || key != "POST /v1/auth/login" || limit.Requests != 10 {
+		t.Fatalf("Lookup(login) = %q, %+v, %v", key, limit, ok)
+	}
+
+	// The default is counted per route, not shared across routes.
+	key, limit, ok = p.Lookup("GET", "/v1/posts")
+	if !ok || key != "GET /v1/posts" || limit.Requests != 120 {
+		t.Fatalf("Lookup(posts) = %q, %+v, %v", key, limit, ok)
+	}
+
+	if _, _, ok := (Policies{}).Lookup("GET", "/v1/posts"); ok {
+		t.Fatal("Lookup() without a default found a limit")
+	}
+}
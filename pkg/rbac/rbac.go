@@ -0,0 +1,183 @@
+package rbac
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule allows an action on a resource. Both may contain glob wildcards,
+// so "posts:*" allows every action on posts and "*:*" allows everything.
+type Rule struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+}
+
+// file is the layout of a policy file. Since JSON is valid YAML, the file
+// may be written in either format:
+//
+//	roles:
+//	  superadmin: ["*:*"]
+//	  user: ["posts:create", "likes:*"]
+type file struct {
+	Roles map[string][]string `yaml:"roles"`
+}
+
+// Engine evaluates permissions against a policy file and reloads it when
+// the file changes on disk.
+type Engine struct {
+	path string
+
+	mu    sync.RWMutex
+	roles map[string][]Rule
+
+	watcher *fsnotify.Watcher
+}
+
+// Load reads the policy file at path.
+func Load(path string) (*Engine, error) {
+	e := &Engine{path: path}
+	if err := e.Reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reload re-reads the policy file. On error the current policy is kept.
+func (e *Engine) Reload() error {
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return err
+	}
+
+	var f file
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("failed to parse policy file: %w", err)
+	}
+
+	// An empty policy would deny everything. It is far more likely to be
+	// a file caught halfway through a rewrite than an intended policy.
+	if len(f.Roles) == 0 {
+		return fmt.Errorf("policy file %s defines no roles", e.path)
+	}
+
+	roles := make(map[string][]Rule, len(f.Roles))
+	for role, permissions := range f.Roles {
+		for _, permission := range permissions {
+			rule, err := parseRule(permission)
+			if err != nil {
+				return fmt.Errorf("role %q: %w", role, err)
+			}
+			roles[role] = append(roles[role], rule)
+		}
+	}
+
+	e.mu.Lock()
+	e.roles = roles
+	e.mu.Unlock()
+
+	return nil
+}
+
+func parseRule(s string) (Rule, error) {
+	resource, action, ok := strings.Cut(s, ":")
+	if !ok || resource == "" || action == "" {
+		return Rule{}, fmt.Errorf("invalid permission %q, want resource:action", s)
+	}
+
+	for _, pattern := range []string{resource, action} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return Rule{}, fmt.Errorf("invalid permission %q: %w", s, err)
+		}
+	}
+
+	return Rule{Resource: resource, Action: action}, nil
+}
+
+// Allowed reports whether role may perform action on resource. Roles
+// missing from the policy are allowed nothing.
+func (e *Engine) Allowed(role, resource, action string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, rule := range e.roles[role] {
+		if rule.matches(resource, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rules returns the effective rules of role.
+func (e *Engine) Rules(role string) ([]Rule, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	rules, ok := e.roles[role]
+	if !ok {
+		return nil, false
+	}
+
+	result := make([]Rule, len(rules))
+	copy(result, rules)
+	return result, true
+}
+
+func (r Rule) matches(resource, action string) bool {
+	resourceMatched, _ := path.Match(r.Resource, resource)
+	actionMatched, _ := path.Match(r.Action, action)
+	return resourceMatched && actionMatched
+}
+
+// Watch reloads the policy whenever its directory changes and reports the
+// outcome of every reload to onReload. The directory is watched rather
+// than the file, so editors and ConfigMap updates that replace the file
+// are picked up too.
+func (e *Engine) Watch(onReload func(error)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := watcher.Add(filepath.Dir(e.path)); err != nil {
+		watcher.Close()
+		return err
+	}
+	e.watcher = watcher
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				onReload(e.Reload())
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				onReload(err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close stops watching the policy file.
+func (e *Engine) Close() error {
+	if e.watcher == nil {
+		return nil
+	}
+	return e.watcher.Close()
+}
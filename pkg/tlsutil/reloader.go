@@ -0,0 +1,184 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// reloadCheckInterval limits how often the files are checked for changes.
+const reloadCheckInterval = 10 * time.Second
+
+// Reloader serves a certificate and a CA bundle from disk and picks up new
+// versions of the files, so certificates can be rotated without a restart.
+// Changes are noticed on the next handshake, or right away on Reload.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	roots     *x509.CertPool
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+// NewReloader loads the key pair and the CA bundle. Either may be left
+// empty: without a key pair no certificate is presented, and without a CA
+// bundle the system roots are trusted.
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("both certificate and key files must be set")
+	}
+
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. On error the previous ones stay in use.
+func (r *Reloader) Reload() error {
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return err
+	}
+
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load key pair: %w", err)
+		}
+		cert = &pair
+	}
+
+	var roots *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return err
+		}
+
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = cert
+	r.roots = roots
+	r.modTimes = modTimes
+	r.lastCheck = time.Now()
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *Reloader) statFiles() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time)
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[file] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// reloadIfChanged reloads the files when any of them was modified since
+// the last load. Errors keep the current files, since a rotation may be
+// caught halfway.
+func (r *Reloader) reloadIfChanged() {
+	r.mu.Lock()
+	if time.Since(r.lastCheck) < reloadCheckInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastCheck = time.Now()
+	previous := r.modTimes
+	r.mu.Unlock()
+
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return
+	}
+
+	for file, modTime := range modTimes {
+		if !modTime.Equal(previous[file]) {
+			_ = r.Reload()
+			return
+		}
+	}
+}
+
+func (r *Reloader) certificate() (*tls.Certificate, error) {
+	r.reloadIfChanged()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.cert == nil {
+		return nil, errors.New("no certificate configured")
+	}
+	return r.cert, nil
+}
+
+// GetCertificate serves the current certificate to TLS clients.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.certificate()
+}
+
+// GetClientCertificate presents the current certificate to TLS servers.
+func (r *Reloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.certificate()
+}
+
+// ClientConfig returns a client configuration with the currently loaded
+// files. serverName overrides the name the server certificate is checked
+// against. RootCAs can't be swapped on a live config, so callers should ask
+// for a new one per connection to pick up a rotated CA bundle.
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	r.reloadIfChanged()
+
+	r.mu.RLock()
+	roots := r.roots
+	r.mu.RUnlock()
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		RootCAs:    roots,
+	}
+
+	if r.certFile != "" {
+		cfg.GetClientCertificate = r.GetClientCertificate
+	}
+
+	return cfg
+}
+
+// ServerConfig returns a server configuration serving the reloadable
+// certificate.
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
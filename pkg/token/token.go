@@ -0,0 +1,206 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
+)
+
+// Supported values of config.Config.AuthMode.
+const (
+	ModeRemote = "remote"
+	ModeLocal  = "local"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token has expired")
+)
+
+// Payload is the identity carried by an access token.
+type Payload struct {
+	ID        string
+	UserID    int64
+	Email     string
+	UserType  string
+	IssuedAt  time.Time
+	ExpiredAt time.Time
+}
+
+// Verifier checks access tokens locally, either against a shared HMAC
+// secret or against the public keys of a JWKS file.
+type Verifier struct {
+	keys map[string]interface{}
+}
+
+// NewVerifier creates the verifier of the configured auth mode. In remote
+// mode tokens are checked by the user service, so it returns nil.
+func NewVerifier(cfg config.Config) (*Verifier, error) {
+	switch cfg.AuthMode {
+	case ModeRemote, "":
+		return nil, nil
+	case ModeLocal:
+		if cfg.AuthJWKSFile != "" {
+			return NewJWKSVerifier(cfg.AuthJWKSFile)
+		}
+		return NewHMACVerifier(cfg.AuthSigningKey)
+	}
+
+	return nil, fmt.Errorf("unknown auth mode %q", cfg.AuthMode)
+}
+
+func NewHMACVerifier(secret string) (*Verifier, error) {
+	if secret == "" {
+		return nil, errors.New("signing key is empty")
+	}
+	return &Verifier{keys: map[string]interface{}{"": []byte(secret)}}, nil
+}
+
+func NewJWKSVerifier(path string) (*Verifier, error) {
+	keys, err := loadJWKS(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{keys: keys}, nil
+}
+
+// Verify checks the signature and expiry of token and returns its payload.
+func (v *Verifier) Verify(token string) (*Payload, error) {
+	claims := jwt.MapClaims{}
+
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	if _, err := parser.ParseWithClaims(token, claims, v.keyFunc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	payload, err := payloadFromClaims(claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if !time.Now().Before(payload.ExpiredAt) {
+		return nil, ErrExpiredToken
+	}
+
+	return payload, nil
+}
+
+// keyFunc picks the key named by the token's kid header, or the only
+// configured key when the header is missing, and makes sure the signing
+// method matches the key type.
+func (v *Verifier) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	key, ok := v.keys[kid]
+	if !ok && kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			key, ok = k, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	var valid bool
+	switch key.(type) {
+	case []byte:
+		_, valid = t.Method.(*jwt.SigningMethodHMAC)
+	case *rsa.PublicKey:
+		switch t.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			valid = true
+		}
+	case *ecdsa.PublicKey:
+		_, valid = t.Method.(*jwt.SigningMethodECDSA)
+	}
+	if !valid {
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	}
+
+	return key, nil
+}
+
+// payloadFromClaims accepts both the user service's claim names and the
+// registered JWT ones (jti, sub, iat, exp).
+func payloadFromClaims(claims jwt.MapClaims) (*Payload, error) {
+	payload := &Payload{
+		ID:       stringClaim(claims, "id", "jti"),
+		Email:    stringClaim(claims, "email"),
+		UserType: stringClaim(claims, "user_type", "type"),
+	}
+
+	userID, err := intClaim(claims, "user_id", "sub")
+	if err != nil {
+		return nil, err
+	}
+	payload.UserID = userID
+
+	if payload.IssuedAt, err = timeClaim(claims, "issued_at", "iat"); err != nil {
+		return nil, err
+	}
+
+	if payload.ExpiredAt, err = timeClaim(claims, "expired_at", "exp"); err != nil {
+		return nil, err
+	}
+	if payload.ExpiredAt.IsZero() {
+		return nil, errors.New("token has no expiry")
+	}
+
+	return payload, nil
+}
+
+func claim(claims jwt.MapClaims, names ...string) (interface{}, string) {
+	for _, name := range names {
+		if v, ok := claims[name]; ok {
+			return v, name
+		}
+	}
+	return nil, ""
+}
+
+func stringClaim(claims jwt.MapClaims, names ...string) string {
+	v, _ := claim(claims, names...)
+	s, _ := v.(string)
+	return s
+}
+
+func intClaim(claims jwt.MapClaims, names ...string) (int64, error) {
+	v, name := claim(claims, names...)
+	switch n := v.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return int64(n), nil
+	case string:
+		i, err := strconv.ParseInt(n, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s claim", name)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("invalid %s claim", name)
+}
+
+// timeClaim reads either a NumericDate or an RFC 3339 timestamp.
+func timeClaim(claims jwt.MapClaims, names ...string) (time.Time, error) {
+	v, name := claim(claims, names...)
+	switch t := v.(type) {
+	case nil:
+		return time.Time{}, nil
+	case float64:
+		return time.Unix(int64(t), 0), nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s claim", name)
+		}
+		return parsed, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s claim", name)
+}
@@ -0,0 +1,279 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+// newTestJWKSVerifier writes the public halves of a new RSA and EC key to a
+// JWKS file, under the key IDs "rsa" and "ec", and loads it.
+func newTestJWKSVerifier(t *testing.T) (*Verifier, testKeys) {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc := base64.RawURLEncoding.EncodeToString
+	set := jwks{Keys: []jwk{
+		{Kty: "RSA", Kid: "rsa", Use: "sig", N: enc(rsaKey.N.Bytes()), E: enc([]byte{1, 0, 1})},
+		{Kty: "EC", Kid: "ec", Crv: "P-256", X: enc(ecKey.X.Bytes()), Y: enc(ecKey.Y.Bytes())},
+		{Kty: "RSA", Kid: "enc", Use: "enc", N: enc(rsaKey.N.Bytes()), E: enc([]byte{1, 0, 1})},
+	}}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewJWKSVerifier(path)
+	if err != nil {
+		t.Fatalf("NewJWKSVerifier() error = %v", err)
+	}
+	if _, ok := v.keys["enc"]; ok {
+		t.Fatal("encryption key loaded as a signing key")
+	}
+
+	return v, testKeys{rsa: rsaKey, ec: ecKey}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+
+	tok := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return s
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": "7",
+		"exp": float64(time.Now().Add(time.Hour).Unix()),
+	}
+}
+
+func TestJWKSVerifierKeys(t *testing.T) {
+	v, keys := newTestJWKSVerifier(t)
+
+	// The RSA modulus is public, so it must not work as an HMAC secret.
+	rsaPublic := keys.rsa.N.Bytes()
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"RS256", sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, validClaims()), false},
+		{"PS256", sign(t, jwt.SigningMethodPS256, "rsa", keys.rsa, validClaims()), false},
+		{"ES256", sign(t, jwt.SigningMethodES256, "ec", keys.ec, validClaims()), false},
+		{"HS256 with RSA key", sign(t, jwt.SigningMethodHS256, "rsa", rsaPublic, validClaims()), true},
+		{"HS256 with EC key", sign(t, jwt.SigningMethodHS256, "ec", keys.ec.X.Bytes(), validClaims()), true},
+		{"ES256 with RSA key", sign(t, jwt.SigningMethodES256, "rsa", keys.ec, validClaims()), true},
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, "other", keys.rsa, validClaims()), true},
+		{"missing kid with several keys", sign(t, jwt.SigningMethodRS256, "", keys.rsa, validClaims()), true},
+		{"encryption key", sign(t, jwt.SigningMethodRS256, "enc", keys.rsa, validClaims()), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := v.Verify(tt.token)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Verify() error = %v, want %v", err, ErrInvalidToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if payload.UserID != 7 {
+				t.Fatalf("UserID = %d, want 7", payload.UserID)
+			}
+		})
+	}
+}
+
+func TestHMACVerifierClaims(t *testing.T) {
+	v, err := NewHMACVerifier("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		want    Payload
+		wantErr error
+	}{
+		{
+			name: "user service claims",
+			claims: jwt.MapClaims{
+				"id":         "abc",
+				"user_id":    float64(7),
+				"email":      "user@example.com",
+				"user_type":  "superadmin",
+				"issued_at":  expiry.Add(-2 * time.Hour).Format(time.RFC3339Nano),
+				"expired_at": expiry.Format(time.RFC3339Nano),
+			},
+			want: Payload{
+				ID:        "abc",
+				UserID:    7,
+				Email:     "user@example.com",
+				UserType:  "superadmin",
+				IssuedAt:  expiry.Add(-2 * time.Hour),
+				ExpiredAt: expiry,
+			},
+		},
+		{
+			name: "registered claims",
+			claims: jwt.MapClaims{
+				"jti":  "abc",
+				"sub":  "7",
+				"type": "user",
+				"iat":  float64(expiry.Add(-2 * time.Hour).Unix()),
+				"exp":  float64(expiry.Unix()),
+			},
+			want: Payload{
+				ID:        "abc",
+				UserID:    7,
+				UserType:  "user",
+				IssuedAt:  expiry.Add(-2 * time.Hour),
+				ExpiredAt: expiry,
+			},
+		},
+		{
+			name:    "missing expiry",
+			claims:  jwt.MapClaims{"user_id": float64(7)},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "invalid expiry",
+			claims:  jwt.MapClaims{"user_id": float64(7), "exp": "tomorrow"},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "invalid user id",
+			claims:  jwt.MapClaims{"sub": "seven", "exp": float64(expiry.Unix())},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "expired",
+			claims:  jwt.MapClaims{"user_id": float64(7), "exp": float64(time.Now().Add(-time.Minute).Unix())},
+			wantErr: ErrExpiredToken,
+		},
+		{
+			name: "expired by the user service claim",
+			claims: jwt.MapClaims{
+				"user_id":    float64(7),
+				"expired_at": time.Now().Add(-time.Minute).Format(time.RFC3339Nano),
+			},
+			wantErr: ErrExpiredToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := v.Verify(sign(t, jwt.SigningMethodHS256, "", []byte("secret"), tt.claims))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+
+			if payload.ID != tt.want.ID || payload.UserID != tt.want.UserID ||
+				payload.Email != tt.want.Email || payload.UserType != tt.want.UserType ||
+				!payload.IssuedAt.Equal(tt.want.IssuedAt) || !payload.ExpiredAt.Equal(tt.want.ExpiredAt) {
+				t.Fatalf("Verify() = %+v, want %+v", *payload, tt.want)
+			}
+		})
+	}
+}
+
+func TestHMACVerifierRejects(t *testing.T) {
+	v, err := NewHMACVerifier("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"wrong secret", sign(t, jwt.SigningMethodHS256, "", []byte("other"), validClaims())},
+		{"RS256", sign(t, jwt.SigningMethodRS256, "", rsaKey, validClaims())},
+		{"unknown kid", sign(t, jwt.SigningMethodHS256, "other", []byte("secret"), validClaims())},
+		{"malformed", "not.a.token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Verify() error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestMakerVerifiedBy(t *testing.T) {
+	maker, err := NewHMACMaker("secret", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewHMACVerifier("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := maker.VerifiedBy(v); err != nil {
+		t.Fatalf("VerifiedBy() error = %v", err)
+	}
+
+	other, err := NewHMACVerifier("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := maker.VerifiedBy(other); err == nil {
+		t.Fatal("VerifiedBy() with another secret error = nil, want error")
+	}
+}
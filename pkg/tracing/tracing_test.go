@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ibrat-muslim/blog_app_api_gateway/config"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// TestPropagation sends a request through otelgin and a gRPC call made with
+// the otelgrpc client interceptor, and checks the spans and the traceparent
+// the backend receives.
+func TestPropagation(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp, err := NewProvider(config.Config{ServiceName: "api_gateway", TracingSampleRatio: 1}, exporter)
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	defer func() {
+		tp.Shutdown(context.Background())
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	}()
+
+	// The backend records the traceparent it was called with.
+	traceparents := make(chan string, 1)
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		traceparents <- first(md.Get("traceparent"))
+		return handler(ctx, req)
+	}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(otelgin.Middleware("api_gateway"))
+	router.GET("/v1/posts/:id", func(ctx *gin.Context) {
+		_, err := healthpb.NewHealthClient(conn).Check(ctx.Request.Context(), &healthpb.HealthCheckRequest{})
+		if err != nil {
+			ctx.Status(http.StatusBadGateway)
+			return
+		}
+		ctx.Status(http.StatusOK)
+	})
+
+	const (
+		clientTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		clientSpanID  = "00f067aa0ba902b7"
+	)
+	req := httptest.NewRequest(http.MethodGet, "/v1/posts/1", nil)
+	req.Header.Set("traceparent", "00-"+clientTraceID+"-"+clientSpanID+"-01")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+
+	var server, client *tracetest.SpanStub
+	spans := exporter.GetSpans()
+	for i := range spans {
+		switch spans[i].SpanKind {
+		case trace.SpanKindServer:
+			server = &spans[i]
+		case trace.SpanKindClient:
+			client = &spans[i]
+		}
+	}
+	if server == nil || client == nil {
+		t.Fatalf("spans = %+v, want a server and a client span", spans)
+	}
+
+	if server.Name != "/v1/posts/:id" {
+		t.Errorf("server span name = %q", server.Name)
+	}
+	if got := server.SpanContext.TraceID().String(); got != clientTraceID {
+		t.Errorf("server span trace = %s, want the caller's %s", got, clientTraceID)
+	}
+	if got := server.Parent.SpanID().String(); got != clientSpanID || !server.Parent.IsRemote() {
+		t.Errorf("server span parent = %s, want remote %s", got, clientSpanID)
+	}
+
+	if client.Name != "grpc.health.v1.Health/Check" {
+		t.Errorf("client span name = %q", client.Name)
+	}
+	if client.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("client span parent = %s, want the server span %s", client.Parent.SpanID(), server.SpanContext.SpanID())
+	}
+
+	want := "00-" + clientTraceID + "-" + client.SpanContext.SpanID().String() + "-01"
+	if got := <-traceparents; got != want {
+		t.Errorf("backend traceparent = %q, want %q", got, want)
+	}
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
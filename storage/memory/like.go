@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage/repo"
+)
+
+// likeRepo keeps likes in process memory. It is meant to be used until the
+// post service exposes likes over gRPC, so data is lost on restart.
+type likeRepo struct {
+	mu     sync.RWMutex
+	lastID int64
+	// likes holds the likes of every post by user ID, and counts their
+	// totals, so reading a post does not scan the likes of other posts.
+	likes  map[int64]map[int64]*repo.Like
+	counts map[int64]*repo.LikesDislikesCountsResult
+}
+
+func NewLike() repo.LikeStorageI {
+	return &likeRepo{
+		likes:  make(map[int64]map[int64]*repo.Like),
+		counts: make(map[int64]*repo.LikesDislikesCountsResult),
+	}
+}
+
+func (lr *likeRepo) CreateOrUpdate(l *repo.Like) (*repo.Like, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	postLikes, ok := lr.likes[l.PostID]
+	if !ok {
+		postLikes = make(map[int64]*repo.Like)
+		lr.likes[l.PostID] = postLikes
+		lr.counts[l.PostID] = &repo.LikesDislikesCountsResult{}
+	}
+
+	like, ok := postLikes[l.UserID]
+	if ok {
+		lr.count(like, -1)
+	} else {
+		lr.lastID++
+		like = &repo.Like{
+			ID:        lr.lastID,
+			PostID:    l.PostID,
+			UserID:    l.UserID,
+			CreatedAt: time.Now(),
+		}
+		postLikes[l.UserID] = like
+	}
+	like.Status = l.Status
+	lr.count(like, 1)
+
+	result := *like
+	return &result, nil
+}
+
+func (lr *likeRepo) Get(userID, postID int64) (*repo.Like, error) {
+	lr.mu.RLock()
+	defer lr.mu.RUnlock()
+
+	like, ok := lr.likes[postID][userID]
+	if !ok {
+		return nil, repo.ErrNotFound
+	}
+
+	result := *like
+	return &result, nil
+}
+
+func (lr *likeRepo) Delete(userID, postID int64) error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	like, ok := lr.likes[postID][userID]
+	if !ok {
+		return repo.ErrNotFound
+	}
+
+	lr.count(like, -1)
+	delete(lr.likes[postID], userID)
+	if len(lr.likes[postID]) == 0 {
+		delete(lr.likes, postID)
+		delete(lr.counts, postID)
+	}
+
+	return nil
+}
+
+func (lr *likeRepo) DeleteByPost(postID int64) error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	delete(lr.likes, postID)
+	delete(lr.counts, postID)
+
+	return nil
+}
+
+func (lr *likeRepo) GetLikesDislikesCount(postID int64) (*repo.LikesDislikesCountsResult, error) {
+	lr.mu.RLock()
+	defer lr.mu.RUnlock()
+
+	var result repo.LikesDislikesCountsResult
+	if counts, ok := lr.counts[postID]; ok {
+		result = *counts
+	}
+
+	return &result, nil
+}
+
+// count adds delta to the like or dislike total of the post of like.
+func (lr *likeRepo) count(like *repo.Like, delta int64) {
+	counts := lr.counts[like.PostID]
+	if like.Status {
+		counts.LikesCount += delta
+	} else {
+		counts.DislikesCount += delta
+	}
+}
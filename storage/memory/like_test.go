@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage/repo"
+)
+
+func TestLikeCounts(t *testing.T) {
+	lr := NewLike()
+
+	set := func(userID, postID int64, status bool) {
+		t.Helper()
+		if _, err := lr.CreateOrUpdate(&repo.Like{UserID: userID, PostID: postID, Status: status}); err != nil {
+			t.Fatalf("CreateOrUpdate() error = %v", err)
+		}
+	}
+	check := func(postID, likes, dislikes int64) {
+		t.Helper()
+		got, err := lr.GetLikesDislikesCount(postID)
+		if err != nil {
+			t.Fatalf("GetLikesDislikesCount() error = %v", err)
+		}
+		if got.LikesCount != likes || got.DislikesCount != dislikes {
+			t.Fatalf("post %d counts = %+v, want %d likes and %d dislikes", postID, *got, likes, dislikes)
+		}
+	}
+
+	set(1, 10, true)
+	set(2, 10, true)
+	set(3, 10, false)
+	set(1, 20, false)
+	check(10, 2, 1)
+	check(20, 0, 1)
+	check(30, 0, 0)
+
+	// Changing a vote moves it to the other total.
+	set(2, 10, false)
+	check(10, 1, 2)
+
+	// Voting the same way again counts once.
+	set(2, 10, false)
+	check(10, 1, 2)
+
+	if err := lr.Delete(3, 10); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	check(10, 1, 1)
+
+	if err := lr.Delete(3, 10); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("Delete() twice error = %v, want %v", err, repo.ErrNotFound)
+	}
+
+	if err := lr.DeleteByPost(10); err != nil {
+		t.Fatalf("DeleteByPost() error = %v", err)
+	}
+	check(10, 0, 0)
+	check(20, 0, 1)
+	if _, err := lr.Get(1, 10); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("Get() after DeleteByPost error = %v, want %v", err, repo.ErrNotFound)
+	}
+
+	// Likes of a deleted post start over.
+	set(1, 10, true)
+	check(10, 1, 0)
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage/repo"
+)
+
+// loginAttemptSweepInterval is how often expired counters are dropped.
+const loginAttemptSweepInterval = time.Minute
+
+// loginAttemptRepo keeps failed attempt counters of a single gateway instance.
+type loginAttemptRepo struct {
+	mu        sync.Mutex
+	attempts  map[string]*loginAttempt
+	lastSweep time.Time
+}
+
+type loginAttempt struct {
+	repo.LoginAttempt
+	expiresAt time.Time
+}
+
+func NewLoginAttempt() repo.LoginAttemptStorageI {
+	return &loginAttemptRepo{
+		attempts:  make(map[string]*loginAttempt),
+		lastSweep: time.Now(),
+	}
+}
+
+func (lr *loginAttemptRepo) Get(key string) (*repo.LoginAttempt, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	a, ok := lr.attempts[key]
+	if !ok || !time.Now().Before(a.expiresAt) {
+		return nil, repo.ErrNotFound
+	}
+
+	result := a.LoginAttempt
+	return &result, nil
+}
+
+func (lr *loginAttemptRepo) AddFailure(key string, window time.Duration) (*repo.LoginAttempt, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	now := time.Now()
+	lr.sweep(now)
+
+	a, ok := lr.attempts[key]
+	if !ok || now.Sub(a.UpdatedAt) >= window {
+		a = &loginAttempt{}
+		lr.attempts[key] = a
+	}
+
+	a.Failures++
+	a.UpdatedAt = now
+	a.extend(now.Add(window))
+
+	result := a.LoginAttempt
+	return &result, nil
+}
+
+func (lr *loginAttemptRepo) Lock(key string, until time.Time) error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	a, ok := lr.attempts[key]
+	if !ok {
+		a = &loginAttempt{}
+		a.UpdatedAt = time.Now()
+		lr.attempts[key] = a
+	}
+
+	a.LockedUntil = until
+	a.extend(until)
+
+	return nil
+}
+
+func (lr *loginAttemptRepo) Reset(key string) error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	delete(lr.attempts, key)
+	return nil
+}
+
+func (a *loginAttempt) extend(t time.Time) {
+	if t.After(a.expiresAt) {
+		a.expiresAt = t
+	}
+}
+
+func (lr *loginAttemptRepo) sweep(now time.Time) {
+	if now.Sub(lr.lastSweep) < loginAttemptSweepInterval {
+		return
+	}
+	lr.lastSweep = now
+
+	for key, a := range lr.attempts {
+		if !now.Before(a.expiresAt) {
+			delete(lr.attempts, key)
+		}
+	}
+}
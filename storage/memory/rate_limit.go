@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage/repo"
+)
+
+// rateLimitSweepInterval is how often buckets that have refilled completely
+// are dropped, since they are indistinguishable from missing ones.
+const rateLimitSweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	fullAt  time.Time
+}
+
+// rateLimitRepo is a token bucket store local to a single gateway instance.
+type rateLimitRepo struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewRateLimit() repo.RateLimitStorageI {
+	return &rateLimitRepo{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (rr *rateLimitRepo) Take(key string, limit int64, period time.Duration) (*repo.RateLimitResult, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	now := time.Now()
+	rr.sweep(now)
+
+	capacity := float64(limit)
+	perToken := float64(period) / capacity
+
+	b, ok := rr.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		rr.buckets[key] = b
+	} else {
+		elapsed := float64(now.Sub(b.updated))
+		b.tokens = math.Min(capacity, b.tokens+elapsed/perToken)
+		b.updated = now
+	}
+
+	result := &repo.RateLimitResult{Limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) * perToken)
+	}
+
+	result.Remaining = int64(b.tokens)
+	result.ResetAfter = time.Duration((capacity - b.tokens) * perToken)
+	b.fullAt = now.Add(result.ResetAfter)
+
+	return result, nil
+}
+
+func (rr *rateLimitRepo) sweep(now time.Time) {
+	if now.Sub(rr.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	rr.lastSweep = now
+
+	for key, b := range rr.buckets {
+		if !now.Before(b.fullAt) {
+			delete(rr.buckets, key)
+		}
+	}
+}
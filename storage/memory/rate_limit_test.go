@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitTake(t *testing.T) {
+	rr := NewRateLimit().(*rateLimitRepo)
+
+	// 5 requests per 10s refill one token every 2s.
+	for i := int64(4); i >= 0; i-- {
+		res, err := rr.Take("k", 5, 10*time.Second)
+		if err != nil {
+			t.Fatalf("Take() error = %v", err)
+		}
+		if !res.Allowed || res.Remaining != i || res.Limit != 5 {
+			t.Fatalf("Take() = %+v, want allowed with %d remaining", *res, i)
+		}
+	}
+
+	res, _ := rr.Take("k", 5, 10*time.Second)
+	if res.Allowed || res.Remaining != 0 {
+		t.Fatalf("Take() on empty bucket = %+v", *res)
+	}
+	if !approx(res.RetryAfter, 2*time.Second) || !approx(res.ResetAfter, 10*time.Second) {
+		t.Fatalf("RetryAfter = %v, ResetAfter = %v, want 2s and 10s", res.RetryAfter, res.ResetAfter)
+	}
+
+	// Half a token later the wait is halved.
+	rr.buckets["k"].updated = rr.buckets["k"].updated.Add(-time.Second)
+	res, _ = rr.Take("k", 5, 10*time.Second)
+	if res.Allowed || !approx(res.RetryAfter, time.Second) || !approx(res.ResetAfter, 9*time.Second) {
+		t.Fatalf("after 1s: %+v", *res)
+	}
+
+	// 3 tokens later, 2 are left after this request.
+	rr.buckets["k"].updated = rr.buckets["k"].updated.Add(-5 * time.Second)
+	res, _ = rr.Take("k", 5, 10*time.Second)
+	if !res.Allowed || res.Remaining != 2 || !approx(res.ResetAfter, 6*time.Second) {
+		t.Fatalf("after refill: %+v", *res)
+	}
+
+	// Refills stop at the limit.
+	rr.buckets["k"].updated = rr.buckets["k"].updated.Add(-time.Hour)
+	res, _ = rr.Take("k", 5, 10*time.Second)
+	if !res.Allowed || res.Remaining != 4 {
+		t.Fatalf("after a long pause: %+v", *res)
+	}
+
+	// Keys do not share tokens.
+	if res, _ := rr.Take("other", 1, time.Minute); !res.Allowed {
+		t.Fatalf("other key: %+v", *res)
+	}
+}
+
+func TestRateLimitSweep(t *testing.T) {
+	rr := NewRateLimit().(*rateLimitRepo)
+
+	rr.Take("full", 5, time.Second)
+	rr.Take("busy", 5, time.Hour)
+	rr.buckets["full"].fullAt = time.Now().Add(-time.Millisecond)
+
+	rr.lastSweep = time.Now().Add(-rateLimitSweepInterval)
+	rr.Take("new", 5, time.Second)
+
+	if _, ok := rr.buckets["full"]; ok {
+		t.Error("refilled bucket not swept")
+	}
+	if _, ok := rr.buckets["busy"]; !ok {
+		t.Error("partially used bucket swept")
+	}
+}
+
+func approx(got, want time.Duration) bool {
+	d := got - want
+	return d > -50*time.Millisecond && d < 50*time.Millisecond
+}
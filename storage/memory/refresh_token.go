@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage/repo"
+)
+
+// refreshTokenSweepInterval is how often expired tokens are dropped.
+const refreshTokenSweepInterval = time.Minute
+
+type refreshToken struct {
+	repo.RefreshToken
+	used bool
+}
+
+// refreshTokenRepo keeps refresh tokens in process memory, so every
+// session ends when the gateway restarts.
+type refreshTokenRepo struct {
+	mu        sync.Mutex
+	tokens    map[string]*refreshToken
+	revoked   map[string]time.Time // family ID to the expiry of its last token
+	lastSweep time.Time
+}
+
+func NewRefreshToken() repo.RefreshTokenStorageI {
+	return &refreshTokenRepo{
+		tokens:    make(map[string]*refreshToken),
+		revoked:   make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+func (rr *refreshTokenRepo) Create(t *repo.RefreshToken) error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	rr.sweep(time.Now())
+
+	rr.tokens[t.Hash] = &refreshToken{RefreshToken: *t}
+	return nil
+}
+
+func (rr *refreshTokenRepo) Get(hash string) (*repo.RefreshToken, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	t, ok := rr.tokens[hash]
+	if !ok {
+		return nil, repo.ErrNotFound
+	}
+
+	result := t.RefreshToken
+	return &result, nil
+}
+
+func (rr *refreshTokenRepo) Use(hash string) (*repo.RefreshToken, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	t, ok := rr.tokens[hash]
+	if !ok {
+		return nil, repo.ErrNotFound
+	}
+
+	result := t.RefreshToken
+	if _, revoked := rr.revoked[t.FamilyID]; revoked {
+		return &result, repo.ErrTokenRevoked
+	}
+	if t.used {
+		return &result, repo.ErrTokenReused
+	}
+
+	t.used = true
+	return &result, nil
+}
+
+func (rr *refreshTokenRepo) RevokeFamily(familyID string) error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	var expiresAt time.Time
+	for _, t := range rr.tokens {
+		if t.FamilyID == familyID && t.ExpiresAt.After(expiresAt) {
+			expiresAt = t.ExpiresAt
+		}
+	}
+
+	if !expiresAt.IsZero() {
+		rr.revoked[familyID] = expiresAt
+	}
+	return nil
+}
+
+func (rr *refreshTokenRepo) sweep(now time.Time) {
+	if now.Sub(rr.lastSweep) < refreshTokenSweepInterval {
+		return
+	}
+	rr.lastSweep = now
+
+	for hash, t := range rr.tokens {
+		if !now.Before(t.ExpiresAt) {
+			delete(rr.tokens, hash)
+		}
+	}
+
+	for familyID, expiresAt := range rr.revoked {
+		if !now.Before(expiresAt) {
+			delete(rr.revoked, familyID)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage/repo"
+)
+
+func TestRefreshTokenUse(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(rr *refreshTokenRepo)
+		hash    string
+		wantErr error
+	}{
+		{
+			name: "first use",
+			hash: "a1",
+		},
+		{
+			name: "reuse",
+			prepare: func(rr *refreshTokenRepo) {
+				if _, err := rr.Use("a1"); err != nil {
+					t.Fatalf("first use: %v", err)
+				}
+			},
+			hash:    "a1",
+			wantErr: repo.ErrTokenReused,
+		},
+		{
+			name: "revoked family",
+			prepare: func(rr *refreshTokenRepo) {
+				if err := rr.RevokeFamily("a"); err != nil {
+					t.Fatalf("revoke: %v", err)
+				}
+			},
+			hash:    "a1",
+			wantErr: repo.ErrTokenRevoked,
+		},
+		{
+			name: "revoked family after reuse",
+			prepare: func(rr *refreshTokenRepo) {
+				if _, err := rr.Use("a1"); err != nil {
+					t.Fatalf("first use: %v", err)
+				}
+				if err := rr.RevokeFamily("a"); err != nil {
+					t.Fatalf("revoke: %v", err)
+				}
+			},
+			hash:    "a1",
+			wantErr: repo.ErrTokenRevoked,
+		},
+		{
+			name: "other family is not revoked",
+			prepare: func(rr *refreshTokenRepo) {
+				if err := rr.RevokeFamily("b"); err != nil {
+					t.Fatalf("revoke: %v", err)
+				}
+			},
+			hash: "a1",
+		},
+		{
+			name:    "unknown token",
+			hash:    "missing",
+			wantErr: repo.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := newTestRefreshTokens(t, time.Now().Add(time.Hour))
+			if tt.prepare != nil {
+				tt.prepare(rr)
+			}
+
+			got, err := rr.Use(tt.hash)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Use() error = %v, want %v", err, tt.wantErr)
+			}
+			if errors.Is(err, repo.ErrNotFound) {
+				return
+			}
+			if got == nil || got.Hash != tt.hash {
+				t.Fatalf("Use() = %+v, want token %q", got, tt.hash)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenRevokeFamily(t *testing.T) {
+	rr := newTestRefreshTokens(t, time.Now().Add(time.Hour))
+
+	if err := rr.RevokeFamily("a"); err != nil {
+		t.Fatalf("RevokeFamily() error = %v", err)
+	}
+
+	for _, hash := range []string{"a1", "a2"} {
+		if _, err := rr.Use(hash); !errors.Is(err, repo.ErrTokenRevoked) {
+			t.Errorf("Use(%q) error = %v, want %v", hash, err, repo.ErrTokenRevoked)
+		}
+	}
+	if _, err := rr.Use("b1"); err != nil {
+		t.Errorf("Use(%q) error = %v, want nil", "b1", err)
+	}
+
+	// A family without tokens has nothing to revoke.
+	if err := rr.RevokeFamily("unknown"); err != nil {
+		t.Fatalf("RevokeFamily() error = %v", err)
+	}
+	if _, ok := rr.revoked["unknown"]; ok {
+		t.Error("unknown family recorded as revoked")
+	}
+}
+
+func TestRefreshTokenSweep(t *testing.T) {
+	rr := newTestRefreshTokens(t, time.Now().Add(-time.Second))
+	if err := rr.RevokeFamily("a"); err != nil {
+		t.Fatalf("RevokeFamily() error = %v", err)
+	}
+
+	// Sweeps are throttled, so nothing is dropped right away.
+	createRefreshToken(t, rr, "c1", "c", time.Now().Add(time.Hour))
+	if _, err := rr.Get("a1"); err != nil {
+		t.Fatalf("expired token swept before the interval: %v", err)
+	}
+
+	rr.lastSweep = time.Now().Add(-refreshTokenSweepInterval)
+	createRefreshToken(t, rr, "c2", "c", time.Now().Add(time.Hour))
+
+	for _, hash := range []string{"a1", "a2", "b1"} {
+		if _, err := rr.Get(hash); !errors.Is(err, repo.ErrNotFound) {
+			t.Errorf("Get(%q) error = %v, want %v", hash, err, repo.ErrNotFound)
+		}
+	}
+	if _, ok := rr.revoked["a"]; ok {
+		t.Error("expired revoked family not swept")
+	}
+	for _, hash := range []string{"c1", "c2"} {
+		if _, err := rr.Get(hash); err != nil {
+			t.Errorf("Get(%q) error = %v, want nil", hash, err)
+		}
+	}
+}
+
+// newTestRefreshTokens returns a store with tokens a1 and a2 of family a and
+// b1 of family b, all expiring at expiresAt.
+func newTestRefreshTokens(t *testing.T, expiresAt time.Time) *refreshTokenRepo {
+	t.Helper()
+
+	rr := NewRefreshToken().(*refreshTokenRepo)
+	createRefreshToken(t, rr, "a1", "a", expiresAt)
+	createRefreshToken(t, rr, "a2", "a", expiresAt)
+	createRefreshToken(t, rr, "b1", "b", expiresAt)
+	return rr
+}
+
+func createRefreshToken(t *testing.T, rr *refreshTokenRepo, hash, familyID string, expiresAt time.Time) {
+	t.Helper()
+
+	err := rr.Create(&repo.RefreshToken{
+		Hash:      hash,
+		FamilyID:  familyID,
+		UserID:    1,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Create(%q) error = %v", hash, err)
+	}
+}
@@ -0,0 +1,30 @@
+package repo
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("not found")
+
+type Like struct {
+	ID        int64
+	PostID    int64
+	UserID    int64
+	Status    bool
+	CreatedAt time.Time
+}
+
+type LikesDislikesCountsResult struct {
+	LikesCount    int64
+	DislikesCount int64
+}
+
+type LikeStorageI interface {
+	CreateOrUpdate(l *Like) (*Like, error)
+	Get(userID, postID int64) (*Like, error)
+	Delete(userID, postID int64) error
+	// DeleteByPost removes every like of a post.
+	DeleteByPost(postID int64) error
+	GetLikesDislikesCount(postID int64) (*LikesDislikesCountsResult, error)
+}
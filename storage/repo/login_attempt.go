@@ -0,0 +1,18 @@
+package repo
+
+import "time"
+
+type LoginAttempt struct {
+	Failures    int64
+	LockedUntil time.Time
+	UpdatedAt   time.Time
+}
+
+type LoginAttemptStorageI interface {
+	Get(key string) (*LoginAttempt, error)
+	// AddFailure records a failed attempt. Failures older than window are
+	// forgotten, so the counter starts over.
+	AddFailure(key string, window time.Duration) (*LoginAttempt, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
@@ -0,0 +1,17 @@
+package repo
+
+import "time"
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
+
+type RateLimitStorageI interface {
+	// Take consumes one request from the bucket identified by key, which
+	// holds limit requests refilled evenly over period.
+	Take(key string, limit int64, period time.Duration) (*RateLimitResult, error)
+}
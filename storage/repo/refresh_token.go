@@ -0,0 +1,35 @@
+package repo
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrTokenReused is returned when a refresh token that was already
+	// rotated is presented again.
+	ErrTokenReused = errors.New("refresh token reused")
+	// ErrTokenRevoked is returned for tokens of a revoked family, e.g. after
+	// logout.
+	ErrTokenRevoked = errors.New("refresh token revoked")
+)
+
+type RefreshToken struct {
+	Hash      string
+	FamilyID  string
+	UserID    int64
+	Email     string
+	UserType  string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type RefreshTokenStorageI interface {
+	Create(t *RefreshToken) error
+	Get(hash string) (*RefreshToken, error)
+	// Use marks the token as rotated and returns it. A token can be used
+	// only once; later calls return ErrTokenReused together with the token.
+	// Tokens of a revoked family return ErrTokenRevoked instead.
+	Use(hash string) (*RefreshToken, error)
+	RevokeFamily(familyID string) error
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage/memory"
+	"github.com/ibrat-muslim/blog_app_api_gateway/storage/repo"
+)
+
+type StorageI interface {
+	Like() repo.LikeStorageI
+	RateLimit() repo.RateLimitStorageI
+	LoginAttempt() repo.LoginAttemptStorageI
+	RefreshToken() repo.RefreshTokenStorageI
+}
+
+type storageInMemory struct {
+	likeRepo         repo.LikeStorageI
+	rateLimitRepo    repo.RateLimitStorageI
+	loginAttemptRepo repo.LoginAttemptStorageI
+	refreshTokenRepo repo.RefreshTokenStorageI
+}
+
+func NewStorageInMemory() StorageI {
+	return &storageInMemory{
+		likeRepo:         memory.NewLike(),
+		rateLimitRepo:    memory.NewRateLimit(),
+		loginAttemptRepo: memory.NewLoginAttempt(),
+		refreshTokenRepo: memory.NewRefreshToken(),
+	}
+}
+
+func (s *storageInMemory) Like() repo.LikeStorageI {
+	return s.likeRepo
+}
+
+func (s *storageInMemory) RateLimit() repo.RateLimitStorageI {
+	return s.rateLimitRepo
+}
+
+func (s *storageInMemory) LoginAttempt() repo.LoginAttemptStorageI {
+	return s.loginAttemptRepo
+}
+
+func (s *storageInMemory) RefreshToken() repo.RefreshTokenStorageI {
+	return s.refreshTokenRepo
+}